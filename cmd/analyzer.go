@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// stopWords are dropped from queries and indexed text so filler words
+// don't create matches
+var stopWords = map[string]bool{
+	"a": true, "about": true, "an": true, "and": true, "any": true, "are": true,
+	"as": true, "at": true, "be": true, "by": true, "can": true, "could": true,
+	"do": true, "does": true, "for": true, "from": true, "get": true, "give": true,
+	"has": true, "have": true, "how": true, "i": true, "if": true, "in": true,
+	"info": true, "information": true, "is": true, "it": true, "its": true,
+	"know": true, "like": true, "me": true, "much": true, "my": true, "of": true,
+	"on": true, "or": true, "please": true, "s": true, "should": true, "show": true,
+	"so": true, "some": true, "tell": true, "than": true, "that": true, "the": true,
+	"then": true, "there": true, "they": true, "this": true, "to": true, "us": true,
+	"want": true, "was": true, "we": true, "what": true, "whats": true, "when": true,
+	"where": true, "which": true, "who": true, "with": true, "would": true,
+	"you": true, "your": true,
+}
+
+// genericLocationWords are parts of location names too common to identify
+// a location on their own
+var genericLocationWords = map[string]bool{
+	"city": true, "county": true, "district": true, "new": true, "north": true,
+	"parish": true, "south": true, "state": true, "town": true,
+}
+
+// topicWords map words that refer to a kind of information to its DataType
+var topicWords = map[string]string{
+	"tax": "tax", "taxes": "tax", "rate": "tax", "rates": "tax",
+	"cost": "cost", "costs": "cost", "price": "cost", "prices": "cost",
+	"expensive": "cost", "cheap": "cost", "budget": "cost", "hotel": "cost",
+	"hotels": "cost", "food": "cost", "daily": "cost", "expenses": "cost",
+	"attractions": "tourist", "attraction": "tourist", "visit": "tourist",
+	"see": "tourist", "sights": "tourist", "best": "tourist", "time": "tourist",
+	"season": "tourist", "tourist": "tourist", "tourism": "tourist",
+}
+
+// tokenize lowercases text and splits it into words, keeping decimals
+// such as "7.25" together and dropping stop words
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+
+	var tokens []string
+	for _, field := range fields {
+		field = strings.Trim(field, ".")
+		if field == "" || stopWords[field] {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// singular folds regular plurals so "beaches" matches "beach". Both
+// indexed text and queries are folded, so stems need not be words.
+func singular(term string) string {
+	switch {
+	case len(term) <= 3 || strings.HasSuffix(term, "ss") || strings.HasSuffix(term, "us") || strings.HasSuffix(term, "is"):
+		return term
+	case strings.HasSuffix(term, "ies"):
+		return strings.TrimSuffix(term, "ies") + "y"
+	case strings.HasSuffix(term, "ches") || strings.HasSuffix(term, "shes") ||
+		strings.HasSuffix(term, "sses") || strings.HasSuffix(term, "xes"):
+		return strings.TrimSuffix(term, "es")
+	case strings.HasSuffix(term, "s"):
+		return strings.TrimSuffix(term, "s")
+	}
+	return term
+}
+
+// searchTerms tokenizes text for full-text search, folding plurals
+func searchTerms(text string) []string {
+	tokens := tokenize(text)
+	for i, token := range tokens {
+		tokens[i] = singular(token)
+	}
+	return tokens
+}
+
+// distinctiveTerms are the folded search terms of a query that can tell
+// records apart. Generic location words such as "county" and topic words
+// such as "tax" are shared by too many records to identify a place.
+func distinctiveTerms(query string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, token := range tokenize(query) {
+		if genericLocationWords[token] || topicWords[token] != "" {
+			continue
+		}
+		terms[singular(token)] = true
+	}
+	return terms
+}
+
+// AnalyzedQuery is a query broken down for retrieval
+type AnalyzedQuery struct {
+	Raw       string
+	Terms     []string        // content words, stop words removed
+	Locations []LocationMatch // locations mentioned, in query order
+	Topics    []string        // data types the query asks about
+}
+
+// AnalyzeQuery tokenizes the query, removes stop words and finds the
+// locations and topics it mentions
+func AnalyzeQuery(query string, resolver *LocationResolver) AnalyzedQuery {
+	analysis := AnalyzedQuery{
+		Raw:       query,
+		Terms:     tokenize(query),
+		Locations: resolver.Resolve(query),
+	}
+
+	seen := make(map[string]bool)
+	for _, term := range analysis.Terms {
+		if topic, ok := topicWords[term]; ok && !seen[topic] {
+			seen[topic] = true
+			analysis.Topics = append(analysis.Topics, topic)
+		}
+	}
+	return analysis
+}
+
+// IsFollowUp reports whether the query only asks about topics, such as
+// "and the hotels?" or "tell me about it", and needs a location from context
+func (q AnalyzedQuery) IsFollowUp() bool {
+	if len(q.Locations) > 0 {
+		return false
+	}
+	for _, term := range q.Terms {
+		if _, ok := topicWords[term]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// asksAbout reports whether the query asks about records of the data
+// type. County tax records answer questions about taxes, and queries
+// naming no topic ask about everything.
+func (q AnalyzedQuery) asksAbout(dataType string) bool {
+	if len(q.Topics) == 0 {
+		return true
+	}
+	for _, topic := range q.Topics {
+		if dataType == topic || strings.HasSuffix(dataType, "_"+topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchText is the query reduced to its content words
+func (q AnalyzedQuery) SearchText() string {
+	return strings.Join(q.Terms, " ")
+}
+
+// BestLocation picks the highest scoring location. Ties go to the location
+// currently under discussion, then to the one the resolver knew first.
+func (q AnalyzedQuery) BestLocation(resolver *LocationResolver, current string) (LocationMatch, bool) {
+	if len(q.Locations) == 0 {
+		return LocationMatch{}, false
+	}
+
+	ranked := append([]LocationMatch(nil), q.Locations...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if (ranked[i].Location == current) != (ranked[j].Location == current) {
+			return ranked[i].Location == current
+		}
+		return resolver.order[ranked[i].Location] < resolver.order[ranked[j].Location]
+	})
+	return ranked[0], true
+}
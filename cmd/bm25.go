@@ -0,0 +1,95 @@
+package cmd
+
+import "math"
+
+// BM25 tuning parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type posting struct {
+	doc int
+	tf  int
+}
+
+// BM25Index is an inverted index scoring records with Okapi BM25 over
+// every field: location, values, value names and source
+type BM25Index struct {
+	records  []Record
+	postings map[string][]posting
+	docLens  []int
+	avgLen   float64
+}
+
+// NewBM25Index builds the inverted index for the records
+func NewBM25Index(records []Record) *BM25Index {
+	idx := &BM25Index{
+		records:  records,
+		postings: make(map[string][]posting),
+		docLens:  make([]int, len(records)),
+	}
+
+	var total int
+	for i, record := range records {
+		tokens := searchTerms(RecordText(record))
+		idx.docLens[i] = len(tokens)
+		total += len(tokens)
+
+		counts := make(map[string]int)
+		for _, token := range tokens {
+			counts[token]++
+		}
+		for term, tf := range counts {
+			idx.postings[term] = append(idx.postings[term], posting{doc: i, tf: tf})
+		}
+	}
+
+	if len(records) > 0 {
+		idx.avgLen = float64(total) / float64(len(records))
+	}
+	return idx
+}
+
+// Len returns the number of indexed records
+func (idx *BM25Index) Len() int {
+	return len(idx.records)
+}
+
+// idf returns the inverse document frequency of a term
+func (idx *BM25Index) idf(term string) float64 {
+	n := float64(len(idx.records))
+	df := float64(len(idx.postings[term]))
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// Search returns the k best scoring records for the query, best first
+func (idx *BM25Index) Search(query string, k int) []ScoredRecord {
+	scores := make(map[int]float64)
+
+	seen := make(map[string]bool)
+	for _, term := range searchTerms(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		idf := idx.idf(term)
+		for _, p := range idx.postings[term] {
+			tf := float64(p.tf)
+			norm := 1 - bm25B + bm25B*float64(idx.docLens[p.doc])/idx.avgLen
+			scores[p.doc] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	results := make([]ScoredRecord, 0, len(scores))
+	for i, score := range scores {
+		results = append(results, ScoredRecord{Record: idx.records[i], Index: i, Score: score})
+	}
+
+	sortScored(results)
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
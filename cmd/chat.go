@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultHistoryTokens is the token budget for earlier turns sent with a
+// question
+const defaultHistoryTokens = 2000
+
+// systemPrompt sets up the assistant answering questions
+const systemPrompt = "You are a travel information assistant. Answer questions about tax rates, " +
+	"tourist attractions and travel costs using only the information given with each question. " +
+	"Use the earlier turns of the conversation to understand follow-up questions."
+
+// estimateTokens approximates the tokens of a text at four characters each
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// messageTokens approximates the tokens of messages
+func messageTokens(messages []ChatMessage) int {
+	total := 0
+	for _, message := range messages {
+		total += estimateTokens(message.Content)
+	}
+	return total
+}
+
+// questionMessage is the user message asking a question with the
+// information retrieved for it
+func questionMessage(question, info string) ChatMessage {
+	if info == "" {
+		return ChatMessage{Role: RoleUser, Content: fmt.Sprintf("Question: %s", question)}
+	}
+	return ChatMessage{Role: RoleUser, Content: fmt.Sprintf("Question: %s\nInformation: %s", question, info)}
+}
+
+// turnMessages replays a turn as a user and an assistant message, with or
+// without the information retrieved for it
+func turnMessages(turn Turn, withContext bool) []ChatMessage {
+	info := ""
+	if withContext {
+		info = turn.Context
+	}
+	return []ChatMessage{
+		questionMessage(turn.Question, info),
+		{Role: RoleAssistant, Content: turn.Answer},
+	}
+}
+
+// Messages builds the chat sent to the provider for a question: a system
+// message, the most recent turns that fit the session's token budget and
+// the question itself. Turns that do not fit with their retrieved
+// information are sent without it; older turns are summarized in the
+// system message within the rest of the budget.
+func (s *Session) Messages(question, info string) []ChatMessage {
+	budget := s.HistoryTokens
+	if budget <= 0 {
+		budget = defaultHistoryTokens
+	}
+	// Keep a quarter of the budget for the summary of older turns
+	remaining := budget * 3 / 4
+
+	var recent []ChatMessage
+	older := len(s.Turns)
+	for ; older > 0; older-- {
+		turn := s.Turns[older-1]
+		messages := turnMessages(turn, true)
+		if messageTokens(messages) > remaining {
+			messages = turnMessages(turn, false)
+		}
+		cost := messageTokens(messages)
+		if cost > remaining {
+			break
+		}
+		remaining -= cost
+		recent = append(messages, recent...)
+	}
+
+	system := systemPrompt
+	if summary := summarizeTurns(s.Turns[:older], remaining+budget/4); summary != "" {
+		system += "\n\n" + summary
+	}
+
+	messages := []ChatMessage{{Role: RoleSystem, Content: system}}
+	messages = append(messages, recent...)
+	return append(messages, questionMessage(question, info))
+}
+
+// summarizeTurns lists the questions of older turns and the locations they
+// were about, newest first until the budget runs out, in order
+func summarizeTurns(turns []Turn, budget int) string {
+	const header = "Earlier in this conversation the user asked:"
+	budget -= estimateTokens(header)
+
+	var lines []string
+	for i := len(turns) - 1; i >= 0; i-- {
+		line := fmt.Sprintf("- %s", turns[i].Question)
+		if turns[i].Location != "" {
+			line += fmt.Sprintf(" (about %s)", turns[i].Location)
+		}
+		cost := estimateTokens(line)
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	slices.Reverse(lines)
+	return header + "\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// comparisonColumn is one column of the side-by-side comparison table
+type comparisonColumn struct {
+	Field    string
+	Header   string
+	Currency bool
+}
+
+var comparisonColumns = []comparisonColumn{
+	{Field: "tax_rate", Header: "Tax Rate"},
+	{Field: "daily_cost", Header: "Daily Cost", Currency: true},
+	{Field: "hotel_avg", Header: "Hotel/Night", Currency: true},
+	{Field: "food_avg", Header: "Food/Day", Currency: true},
+	{Field: "best_time", Header: "Best Time to Visit"},
+}
+
+// DistinctLocations returns one location per place in the query where a
+// location was mentioned, in query order. Ambiguous words such as "Travis"
+// resolve to their best candidate.
+func (q AnalyzedQuery) DistinctLocations(resolver *LocationResolver, current string) []LocationMatch {
+	byPosition := make(map[int][]LocationMatch)
+	var positions []int
+	for _, match := range q.Locations {
+		if _, ok := byPosition[match.Position]; !ok {
+			positions = append(positions, match.Position)
+		}
+		byPosition[match.Position] = append(byPosition[match.Position], match)
+	}
+	sort.Ints(positions)
+
+	seen := make(map[string]bool)
+	var locations []LocationMatch
+	for _, position := range positions {
+		candidates := AnalyzedQuery{Locations: byPosition[position]}
+		best, _ := candidates.BestLocation(resolver, current)
+		if seen[best.Location] {
+			continue
+		}
+		seen[best.Location] = true
+		locations = append(locations, best)
+	}
+	return locations
+}
+
+// currencyAmount prefixes a numeric amount with a dollar sign, leaving
+// amounts that already have one and values such as "n/a" as they are
+func currencyAmount(value string) string {
+	if _, ok := ParseNumeric(value); !ok || strings.HasPrefix(value, "$") {
+		return value
+	}
+	return "$" + value
+}
+
+// FormatComparison renders a side-by-side table of the merged key values
+// of each location, followed by the sources used
+func FormatComparison(locations []string, records []Record) string {
+	records = mergedRecords(records)
+	values := make(map[string]map[string]string)
+	sources := make(map[string][]string)
+	for _, location := range locations {
+		values[location] = make(map[string]string)
+	}
+
+	for _, record := range records {
+		locationValues, ok := values[record.Location]
+		if !ok {
+			continue
+		}
+		used := false
+		for _, column := range comparisonColumns {
+			value := record.Values[column.Field]
+			if value == "" || locationValues[column.Field] != "" {
+				continue
+			}
+			if column.Currency {
+				value = currencyAmount(value)
+			}
+			locationValues[column.Field] = value
+			used = true
+		}
+		if used && record.Source != "" {
+			sources[record.Location] = append(sources[record.Location], record.Source)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Comparison of %s:\n", joinAnd(locations))
+
+	headers := []string{"Location"}
+	for _, column := range comparisonColumns {
+		headers = append(headers, column.Header)
+	}
+	b.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat("---|", len(headers)) + "\n")
+
+	for _, location := range locations {
+		row := []string{location}
+		for _, column := range comparisonColumns {
+			value := values[location][column.Field]
+			if value == "" {
+				value = "n/a"
+			}
+			row = append(row, value)
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+
+	b.WriteString("Sources:")
+	for _, location := range locations {
+		if len(sources[location]) > 0 {
+			fmt.Fprintf(&b, "\n- %s: %s", location, strings.Join(sources[location], ", "))
+		}
+	}
+	return b.String()
+}
+
+// joinAnd joins items as "a, b and c"
+func joinAnd(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DataTypeDocument is the data type of chunks of unstructured documents
+const DataTypeDocument = "document"
+
+// Chunking limits for documents
+const (
+	documentChunkWords   = 120
+	documentOverlapWords = 30
+)
+
+// Provenance locates a document chunk in its file
+type Provenance struct {
+	File      string `json:"file"`
+	Page      int    `json:"page,omitempty"`
+	Heading   string `json:"heading,omitempty"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+func init() {
+	RegisterLoader(".md", loadDocument)
+	RegisterLoader(".txt", loadDocument)
+}
+
+// documentWord is a word of a document with the line it appears on
+type documentWord struct {
+	Text string
+	Line int
+}
+
+// documentSection is the text under one heading or on one PDF page
+type documentSection struct {
+	Page    int
+	Heading string
+	Words   []documentWord
+}
+
+// loadDocument splits a Markdown or plain-text file into overlapping chunks
+// that never cross a heading. Chunks are prose rather than fields, so only
+// null bytes are rejected; location tags are attached by TagDocuments once
+// the known locations are loaded.
+func loadDocument(r io.Reader, source DataSource, schema *Schema) ([]Record, error) {
+	sections, err := readSections(r, filepath.Ext(source.Path) == ".md")
+	if err != nil {
+		return nil, err
+	}
+
+	records := documentRecords(source, sections)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("document is empty")
+	}
+	return records, nil
+}
+
+// documentRecords turns the chunks of each section into document Records
+func documentRecords(source DataSource, sections []documentSection) []Record {
+	dataType := source.DataType
+	if dataType == "" {
+		dataType = DataTypeDocument
+	}
+
+	var result []Record
+	for _, section := range sections {
+		for _, chunk := range chunkWords(section.Words, documentChunkWords, documentOverlapWords) {
+			words := make([]string, len(chunk))
+			for i, word := range chunk {
+				words[i] = word.Text
+			}
+
+			values := map[string]string{"text": strings.Join(words, " ")}
+			if section.Heading != "" {
+				values["heading"] = section.Heading
+			}
+			result = append(result, Record{
+				DataType: dataType,
+				Values:   values,
+				Source:   filepath.Base(source.Path),
+				Provenance: &Provenance{
+					File:      source.Path,
+					Page:      section.Page,
+					Heading:   section.Heading,
+					StartLine: chunk[0].Line,
+					EndLine:   chunk[len(chunk)-1].Line,
+				},
+			})
+		}
+	}
+	return result
+}
+
+// readSections splits a document at its Markdown headings. Headings are
+// tracked as a path such as "Texas > Sales tax".
+func readSections(r io.Reader, markdown bool) ([]documentSection, error) {
+	var sections []documentSection
+	var headings []string
+	current := documentSection{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.Contains(text, "\x00") {
+			return nil, fmt.Errorf("invalid data at line %d: invalid character in field", line)
+		}
+
+		if match := headingPattern.FindStringSubmatch(text); markdown && match != nil {
+			if len(current.Words) > 0 {
+				sections = append(sections, current)
+			}
+			level := len(match[1])
+			if len(headings) >= level {
+				headings = headings[:level-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, match[2])
+			current = documentSection{Heading: joinHeadings(headings)}
+			continue
+		}
+
+		for _, word := range strings.Fields(text) {
+			current.Words = append(current.Words, documentWord{Text: word, Line: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading document: %v", err)
+	}
+
+	if len(current.Words) > 0 {
+		sections = append(sections, current)
+	}
+	return sections, nil
+}
+
+func joinHeadings(headings []string) string {
+	var parts []string
+	for _, heading := range headings {
+		if heading != "" {
+			parts = append(parts, heading)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+// chunkWords splits words into windows of size words overlapping by overlap
+func chunkWords(words []documentWord, size, overlap int) [][]documentWord {
+	if len(words) == 0 {
+		return nil
+	}
+	var chunks [][]documentWord
+	step := size - overlap
+	for start := 0; ; start += step {
+		end := min(start+size, len(words))
+		chunks = append(chunks, words[start:end])
+		if end == len(words) {
+			break
+		}
+	}
+	return chunks
+}
+
+// isDocument reports whether the record is a document chunk
+func isDocument(record Record) bool {
+	return record.Provenance != nil
+}
+
+// TagDocuments returns a copy of the records where each document chunk
+// carries the locations it mentions, heading first, by exact name or alias,
+// followed by the locations whose rows cite the document as their source.
+// The first tag becomes the chunk's location so it is found alongside the
+// tabular data.
+func TagDocuments(records []Record, resolver *LocationResolver) []Record {
+	records = slices.Clone(records)
+	citations := make(map[string][]string)
+	for _, record := range records {
+		if isDocument(record) || record.Location == "" {
+			continue
+		}
+		if !slices.Contains(citations[record.Source], record.Location) {
+			citations[record.Source] = append(citations[record.Source], record.Location)
+		}
+	}
+
+	for i := range records {
+		if !isDocument(records[i]) {
+			continue
+		}
+
+		var tags []string
+		seen := make(map[string]bool)
+		for _, text := range []string{records[i].Values["heading"], records[i].Values["text"]} {
+			for _, match := range resolver.Resolve(text) {
+				if match.Fuzzy || match.Partial || seen[match.Location] {
+					continue
+				}
+				seen[match.Location] = true
+				tags = append(tags, match.Location)
+			}
+		}
+		for _, location := range citations[records[i].Source] {
+			if !seen[location] {
+				seen[location] = true
+				tags = append(tags, location)
+			}
+		}
+
+		records[i].Tags = tags
+		records[i].Location = ""
+		if len(tags) > 0 {
+			records[i].Location = tags[0]
+		}
+	}
+	return records
+}
+
+// hasTag reports whether the record is about the location
+func (r Record) hasTag(location string) bool {
+	return slices.Contains(r.Tags, location)
+}
+
+// documentPassage returns the document chunk mentioning the location that
+// best matches the query
+func documentPassage(corpus *Corpus, query, location string) (Record, bool) {
+	var fallback *Record
+	records, _ := corpus.Store.ByLocation(location)
+	for i := range records {
+		if isDocument(records[i]) && records[i].hasTag(location) {
+			fallback = &records[i]
+			break
+		}
+	}
+	if fallback == nil {
+		return Record{}, false
+	}
+
+	results, err := corpus.Store.Search(query, 0)
+	if err != nil {
+		return *fallback, true
+	}
+	for _, result := range results {
+		if isDocument(result.Record) && result.Record.hasTag(location) {
+			return result.Record, true
+		}
+	}
+	return *fallback, true
+}
+
+// formatDocument quotes a document chunk with its provenance
+func formatDocument(record Record) string {
+	where := fmt.Sprintf("lines %d-%d", record.Provenance.StartLine, record.Provenance.EndLine)
+	if record.Provenance.Page > 0 {
+		where = fmt.Sprintf("page %d, %s", record.Provenance.Page, where)
+	}
+	if record.Provenance.Heading != "" {
+		where = record.Provenance.Heading + ", " + where
+	}
+	return fmt.Sprintf("From %s (%s):\n  %s", record.Source, where, record.Values["text"])
+}
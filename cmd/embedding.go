@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Embedder turns texts into dense vectors for similarity search
+type Embedder interface {
+	Name() string
+	Dimensions() int
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+const (
+	EmbedderHashing = "hashing"
+	EmbedderOpenAI  = "openai"
+
+	defaultHashingDimensions = 2048
+)
+
+// HashingEmbedder is an offline embedder using the hashing trick over
+// words and word bigrams. It needs no training and no network access.
+type HashingEmbedder struct {
+	dims int
+}
+
+// NewHashingEmbedder creates a hashing embedder with the given dimensions
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	if dims <= 0 {
+		dims = defaultHashingDimensions
+	}
+	return &HashingEmbedder{dims: dims}
+}
+
+// Name identifies the embedder and its configuration
+func (e *HashingEmbedder) Name() string {
+	return fmt.Sprintf("%s-%d", EmbedderHashing, e.dims)
+}
+
+// Dimensions returns the vector size
+func (e *HashingEmbedder) Dimensions() int {
+	return e.dims
+}
+
+// Embed hashes each text into an L2-normalised vector
+func (e *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashingEmbedder) embed(text string) []float32 {
+	tokens := tokenize(text)
+	counts := make(map[string]float64)
+	for i, token := range tokens {
+		counts[token]++
+		if i > 0 {
+			counts[tokens[i-1]+" "+token] += 0.5
+		}
+	}
+
+	vector := make([]float32, e.dims)
+	for feature, count := range counts {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+
+		// Sublinear term frequency dampens repeated words
+		weight := 1 + math.Log(count)
+		if count < 1 {
+			weight = count
+		}
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[int(sum%uint32(e.dims))] += float32(weight)
+	}
+
+	normalize(vector)
+	return vector
+}
+
+// OpenAIEmbedder uses the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client *openai.Client
+	model  openai.EmbeddingModel
+	dims   int
+}
+
+// NewOpenAIEmbedder creates an embedder backed by the OpenAI API
+func NewOpenAIEmbedder(client *openai.Client) *OpenAIEmbedder {
+	return &OpenAIEmbedder{client: client, model: openai.SmallEmbedding3, dims: 1536}
+}
+
+// Name identifies the embedding model
+func (e *OpenAIEmbedder) Name() string {
+	return fmt.Sprintf("%s-%s", EmbedderOpenAI, e.model)
+}
+
+// Dimensions returns the vector size
+func (e *OpenAIEmbedder) Dimensions() int {
+	return e.dims
+}
+
+// Embed requests embeddings for all texts in one call
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: e.model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %v", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding request returned %d vectors for %d texts", len(resp.Data), len(texts))
+	}
+
+	// The response orders vectors by index; reject indices that are out of
+	// range, repeated or leave a text without a vector
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding request returned a vector for index %d of %d texts", item.Index, len(texts))
+		}
+		if vectors[item.Index] != nil {
+			return nil, fmt.Errorf("embedding request returned index %d twice", item.Index)
+		}
+		if len(item.Embedding) == 0 {
+			return nil, fmt.Errorf("embedding request returned an empty vector for index %d", item.Index)
+		}
+		normalize(item.Embedding)
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// NewEmbedder builds the embedder selected by name
+func NewEmbedder(name string) (Embedder, error) {
+	switch strings.ToLower(name) {
+	case "", EmbedderHashing:
+		return NewHashingEmbedder(defaultHashingDimensions), nil
+	case EmbedderOpenAI:
+		cfg := providerConfigFromEnv(providerConfig)
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY is required for the %s embedder", EmbedderOpenAI)
+		}
+		return NewOpenAIEmbedder(openai.NewClient(cfg.APIKey)), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder %q", name)
+	}
+}
+
+// normalize scales the vector to unit length in place
+func normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
+
+// cosineSimilarity compares two vectors of equal length
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Optional columns dating a record
+const (
+	effectiveDateColumn = "effective_date"
+	validUntilColumn    = "valid_until"
+)
+
+// dateLayout is how dates are shown in answers
+const dateLayout = "2006-01-02"
+
+// defaultStaleAfter is the age past which data is flagged as possibly out
+// of date
+const defaultStaleAfter = 365 * 24 * time.Hour
+
+// dateLayouts are the accepted date formats, most specific first
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"01/02/2006",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2006-01",
+	"2006",
+}
+
+// parseDate parses a date in any of the accepted layouts
+func parseDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, raw); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a date such as 2024-01-31, got %q", raw)
+}
+
+// setDates parses the effective_date and valid_until values of a record
+func (r *Record) setDates(effective, validUntil string) error {
+	for _, column := range []struct {
+		name   string
+		raw    string
+		target *time.Time
+	}{
+		{effectiveDateColumn, effective, &r.EffectiveDate},
+		{validUntilColumn, validUntil, &r.ValidUntil},
+	} {
+		if strings.TrimSpace(column.raw) == "" {
+			continue
+		}
+		date, err := parseDate(column.raw)
+		if err != nil {
+			return &columnError{column: column.name, err: err}
+		}
+		*column.target = date
+	}
+
+	if !r.EffectiveDate.IsZero() && !r.ValidUntil.IsZero() && r.ValidUntil.Before(r.EffectiveDate) {
+		return &columnError{column: validUntilColumn, err: fmt.Errorf("%s is before the effective date %s",
+			r.ValidUntil.Format(dateLayout), r.EffectiveDate.Format(dateLayout))}
+	}
+	return nil
+}
+
+// ValidAt reports whether the record applies at t. Undated records always
+// apply; valid_until includes the whole day.
+func (r Record) ValidAt(t time.Time) bool {
+	if !r.EffectiveDate.IsZero() && t.Before(r.EffectiveDate) {
+		return false
+	}
+	return r.ValidUntil.IsZero() || t.Before(r.ValidUntil.AddDate(0, 0, 1))
+}
+
+// currentRecords narrows a group of records about the same data to those
+// in effect at t with the latest effective date. When none is in effect
+// the whole group is kept so the answer can still warn about it.
+func currentRecords(records []Record, group []int, t time.Time) []int {
+	var valid []int
+	for _, i := range group {
+		if records[i].ValidAt(t) {
+			valid = append(valid, i)
+		}
+	}
+	if len(valid) == 0 {
+		return group
+	}
+
+	var latest time.Time
+	for _, i := range valid {
+		if records[i].EffectiveDate.After(latest) {
+			latest = records[i].EffectiveDate
+		}
+	}
+	var current []int
+	for _, i := range valid {
+		if records[i].EffectiveDate.Equal(latest) {
+			current = append(current, i)
+		}
+	}
+	return current
+}
+
+// staleWarning warns when a record expired or is older than staleAfter
+func staleWarning(record Record, now time.Time, staleAfter time.Duration) (string, bool) {
+	if !record.ValidUntil.IsZero() && !now.Before(record.ValidUntil.AddDate(0, 0, 1)) {
+		return fmt.Sprintf("Warning: this data expired on %s.", record.ValidUntil.Format(dateLayout)), true
+	}
+	if record.EffectiveDate.IsZero() || staleAfter <= 0 || now.Sub(record.EffectiveDate) <= staleAfter {
+		return "", false
+	}
+	return fmt.Sprintf("Warning: this data is from %s, more than %d days ago, and may be out of date.",
+		record.EffectiveDate.Format(dateLayout), int(staleAfter.Hours()/24)), true
+}
+
+// withFreshness adds the as-of date of a dated record to its description,
+// followed by a warning when the data is stale
+func withFreshness(record Record, info string) string {
+	if record.EffectiveDate.IsZero() && record.ValidUntil.IsZero() {
+		return info
+	}
+
+	if !record.EffectiveDate.IsZero() {
+		date := record.EffectiveDate.Format(dateLayout)
+		if strings.Contains(info, "\n") {
+			info += "\n  - As of: " + date
+		} else {
+			info += " (as of " + date + ")"
+		}
+	}
+	if warning, ok := staleWarning(record, time.Now(), staleAfter); ok {
+		info += "\n  " + warning
+	}
+	return info
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parentField is the column naming the state a county belongs to
+const parentField = "state"
+
+// LocationHierarchy links counties to the state they belong to
+type LocationHierarchy struct {
+	parents  map[string]string
+	children map[string][]string
+}
+
+// NewLocationHierarchy builds the hierarchy from records carrying a state
+// column, such as the rows of county_taxes.csv
+func NewLocationHierarchy(records []Record) *LocationHierarchy {
+	h := &LocationHierarchy{
+		parents:  make(map[string]string),
+		children: make(map[string][]string),
+	}
+
+	for _, record := range records {
+		parent := record.Values[parentField]
+		if parent == "" || record.Location == "" || parent == record.Location {
+			continue
+		}
+		if _, ok := h.parents[record.Location]; ok {
+			continue
+		}
+		h.parents[record.Location] = parent
+		h.children[parent] = append(h.children[parent], record.Location)
+	}
+
+	for parent := range h.children {
+		sort.Strings(h.children[parent])
+	}
+	return h
+}
+
+// Parent returns the state a location belongs to, if any
+func (h *LocationHierarchy) Parent(location string) (string, bool) {
+	parent, ok := h.parents[location]
+	return parent, ok
+}
+
+// Children returns the counties of a state in alphabetical order
+func (h *LocationHierarchy) Children(location string) []string {
+	return h.children[location]
+}
+
+// IsChild reports whether the location belongs to another one
+func (h *LocationHierarchy) IsChild(location string) bool {
+	_, ok := h.parents[location]
+	return ok
+}
+
+// taxRateOf returns the first parsable tax rate of a location
+func taxRateOf(records []Record, location string) (NumericValue, Record, bool) {
+	for _, record := range records {
+		if record.Location != location {
+			continue
+		}
+		if rate, ok := record.Number("tax_rate"); ok {
+			return rate, record, true
+		}
+	}
+	return NumericValue{}, Record{}, false
+}
+
+// CombinedTaxRate reports the merged state plus county rate of a county,
+// such as
+// "Combined tax rate in Travis County: 8.15% (Texas 6.25% + Travis County 1.90%)"
+func CombinedTaxRate(records []Record, hierarchy *LocationHierarchy, county string) (string, bool) {
+	state, ok := hierarchy.Parent(county)
+	if !ok {
+		return "", false
+	}
+
+	records = mergedRecords(records)
+	countyRate, _, ok := taxRateOf(records, county)
+	if !ok {
+		return "", false
+	}
+	stateRate, _, ok := taxRateOf(records, state)
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("Combined tax rate in %s: %s (%s %s + %s %s)",
+		county, formatNumeric(stateRate.Value+countyRate.Value, UnitPercent),
+		state, formatNumeric(stateRate.Value, UnitPercent),
+		county, formatNumeric(countyRate.Value, UnitPercent)), true
+}
+
+// countyRatesSummary lists the merged county tax rates of a state
+func countyRatesSummary(records []Record, hierarchy *LocationHierarchy, state string) (string, bool) {
+	records = mergedRecords(records)
+	var parts []string
+	for _, county := range hierarchy.Children(state) {
+		if rate, _, ok := taxRateOf(records, county); ok {
+			parts = append(parts, fmt.Sprintf("%s %s", county, formatNumeric(rate.Value, UnitPercent)))
+		}
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("County tax rates in %s: %s", state, strings.Join(parts, ", ")), true
+}
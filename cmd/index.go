@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// indexFormatVersion is bumped whenever the index file layout changes
+const indexFormatVersion = 4
+
+// IndexFile is the on-disk vector index
+type IndexFile struct {
+	Version    int             `json:"version"`
+	Embedder   string          `json:"embedder"`
+	Dimensions int             `json:"dimensions"`
+	BuiltAt    time.Time       `json:"built_at"`
+	Sources    []IndexedSource `json:"sources"`
+}
+
+// IndexedSource holds the records and chunks embedded from one data file.
+// Chunk.Record indexes into Records.
+type IndexedSource struct {
+	DataSource
+	Hash    string        `json:"hash"`
+	Records []Record      `json:"records"`
+	Chunks  []VectorChunk `json:"chunks"`
+}
+
+// IndexUpdate reports what happened to each source during an update
+type IndexUpdate struct {
+	Reused   []string
+	Embedded []string
+	Failed   map[string]error
+}
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the on-disk vector index",
+}
+
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Embed all data files into the on-disk vector index",
+	Long: `Load every data file, chunk and embed its records and write the
+vector index used by the query command. Files whose content hash did not
+change since the last build are reused without re-embedding.`,
+	RunE: runIndexBuild,
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexBuildCmd)
+	indexBuildCmd.Flags().StringVar(&embedderName, "embedder", EmbedderHashing, "embedder used for vector search: hashing or openai")
+	indexBuildCmd.Flags().Bool("force", false, "re-embed every file even if unchanged")
+}
+
+// hashFile returns the sha256 content hash of a file
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("error reading file: %v", err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadIndex reads an index file. A missing file returns nil without error.
+func LoadIndex(path string) (*IndexFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading index: %v", err)
+	}
+
+	var index IndexFile
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("error parsing index: %v", err)
+	}
+	if index.Version != indexFormatVersion {
+		return nil, fmt.Errorf("unsupported index version %d (expected %d), run 'goragagent index build'",
+			index.Version, indexFormatVersion)
+	}
+	return &index, nil
+}
+
+// SaveIndex writes the index file atomically
+func SaveIndex(path string, index *IndexFile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating index directory: %v", err)
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("error encoding index: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing index: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing index: %v", err)
+	}
+	return nil
+}
+
+// UpdateIndex brings the index in line with the sources. Sources whose
+// content hash is unchanged keep their embeddings; the rest are loaded and
+// embedded again. A nil or incompatible index is rebuilt from scratch.
+func UpdateIndex(ctx context.Context, index *IndexFile, sources []DataSource, embedder Embedder) (*IndexFile, IndexUpdate, error) {
+	update := IndexUpdate{Failed: make(map[string]error)}
+
+	previous := make(map[string]IndexedSource)
+	if index != nil && index.Embedder == embedder.Name() && index.Dimensions == embedder.Dimensions() {
+		for _, source := range index.Sources {
+			previous[source.Path] = source
+		}
+	}
+
+	updated := &IndexFile{
+		Version:    indexFormatVersion,
+		Embedder:   embedder.Name(),
+		Dimensions: embedder.Dimensions(),
+		BuiltAt:    time.Now().UTC(),
+	}
+
+	for _, source := range sources {
+		hash, err := hashFile(source.Path)
+		if err != nil {
+			update.Failed[source.Path] = err
+			continue
+		}
+
+		if old, ok := previous[source.Path]; ok && old.Hash == hash && reflect.DeepEqual(old.DataSource, source) {
+			updated.Sources = append(updated.Sources, old)
+			update.Reused = append(update.Reused, source.Path)
+			continue
+		}
+
+		records, err := LoadSource(source)
+		if err != nil {
+			update.Failed[source.Path] = err
+			continue
+		}
+
+		chunks, err := EmbedRecords(ctx, embedder, records)
+		if err != nil {
+			return nil, update, err
+		}
+
+		updated.Sources = append(updated.Sources, IndexedSource{
+			DataSource: source,
+			Hash:       hash,
+			Records:    records,
+			Chunks:     chunks,
+		})
+		update.Embedded = append(update.Embedded, source.Path)
+	}
+
+	return updated, update, nil
+}
+
+// Corpus builds a searchable corpus from the index without re-embedding,
+// resolving locations through the given aliases
+func (index *IndexFile) Corpus(embedder Embedder, aliases map[string]string) *Corpus {
+	var records []Record
+	var chunks []VectorChunk
+	for _, source := range index.Sources {
+		offset := len(records)
+		records = append(records, source.Records...)
+		for _, chunk := range source.Chunks {
+			chunk.Record += offset
+			chunks = append(chunks, chunk)
+		}
+	}
+	return newCorpus(records, NewVectorIndex(embedder, records, chunks), aliases, nil)
+}
+
+// OpenIndexedCorpus loads the index at path, refreshes changed sources and
+// saves it back when anything was re-embedded
+func OpenIndexedCorpus(ctx context.Context, path string, sources []DataSource, embedder Embedder, aliases map[string]string) (*Corpus, IndexUpdate, error) {
+	index, err := LoadIndex(path)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	index, update, err := UpdateIndex(ctx, index, sources, embedder)
+	if err != nil {
+		return nil, update, err
+	}
+
+	if len(update.Embedded) > 0 {
+		if err := SaveIndex(path, index); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	return index.Corpus(embedder, aliases), update, nil
+}
+
+func runIndexBuild(cmd *cobra.Command, args []string) error {
+	embedder, err := NewEmbedder(embedderName)
+	if err != nil {
+		return err
+	}
+
+	var index *IndexFile
+	if force, _ := cmd.Flags().GetBool("force"); !force {
+		if index, err = LoadIndex(indexPath); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	sources, err := configuredSources()
+	if err != nil {
+		return err
+	}
+	index, update, err := UpdateIndex(context.Background(), index, sources, embedder)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range slices.Sorted(maps.Keys(update.Failed)) {
+		fmt.Printf("Warning: Error loading %s: %v\n", path, update.Failed[path])
+	}
+	if len(index.Sources) == 0 {
+		return fmt.Errorf("no records loaded")
+	}
+
+	if err := SaveIndex(indexPath, index); err != nil {
+		return err
+	}
+
+	var records, chunks int
+	for _, source := range index.Sources {
+		records += len(source.Records)
+		chunks += len(source.Chunks)
+	}
+	fmt.Printf("Indexed %d records (%d chunks) from %d files into %s\n",
+		records, chunks, len(index.Sources), indexPath)
+	fmt.Printf("Embedded: %d, unchanged: %d, embedder: %s\n",
+		len(update.Embedded), len(update.Reused), index.Embedder)
+	return nil
+}
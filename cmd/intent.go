@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Intent is the kind of question asked, which decides how it is answered
+type Intent string
+
+// Intents recognised by the classifiers
+const (
+	IntentHistory    Intent = "history"      // the conversation itself, e.g. "which locations did I ask about?"
+	IntentLookup     Intent = "lookup"       // information about one location
+	IntentCompare    Intent = "compare"      // several locations side by side
+	IntentAggregate  Intent = "aggregate"    // superlatives, filters and averages over every record
+	IntentPlan       Intent = "plan"         // planning a trip to a location
+	IntentSmalltalk  Intent = "smalltalk"    // greetings and thanks
+	IntentOutOfScope Intent = "out-of-scope" // anything the data cannot answer
+)
+
+// intents lists every intent, in the order the LLM classifier is told them
+var intents = []Intent{
+	IntentHistory, IntentLookup, IntentCompare, IntentAggregate, IntentPlan, IntentSmalltalk, IntentOutOfScope,
+}
+
+// Intent classifiers
+const (
+	ClassifierRules = "rules"
+	ClassifierLLM   = "llm"
+)
+
+// IntentRequest is a question to classify. Query is the question as asked
+// and Search its standalone rewrite; Locations are the distinct locations
+// the rewrite names.
+type IntentRequest struct {
+	Query     string
+	Search    string
+	Analysis  AnalyzedQuery
+	Locations []string
+}
+
+// IntentClassifier decides the intent of a question
+type IntentClassifier interface {
+	Name() string
+	Classify(ctx context.Context, req IntentRequest) (Intent, error)
+}
+
+// NewIntentClassifier creates the named classifier. The LLM classifier
+// requires a provider.
+func NewIntentClassifier(name string, provider LLMProvider) (IntentClassifier, error) {
+	switch strings.ToLower(name) {
+	case "", ClassifierRules:
+		return RuleClassifier{}, nil
+	case ClassifierLLM:
+		if provider == nil {
+			return nil, fmt.Errorf("the %s intent classifier requires an LLM provider", ClassifierLLM)
+		}
+		return &LLMClassifier{Provider: provider}, nil
+	default:
+		return nil, fmt.Errorf("unknown intent classifier %q", name)
+	}
+}
+
+// historyPatterns match questions about the conversation itself. They need
+// the user or the conversation as the subject, so "which locations have
+// beaches?" is not one.
+var historyPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:did|have|had)\s+(?:i|we)\s+(?:ask|asked|search|searched|discuss|discussed|talk|talked|look|looked|mention|mentioned)\b`),
+	regexp.MustCompile(`\b(?:previous|earlier|past|recent)\s+(?:questions?|queries|searches|locations|places|conversation)\b`),
+	regexp.MustCompile(`\b(?:my|our|conversation|chat|search|question)\s+history\b`),
+	regexp.MustCompile(`\b(?:do|can)\s+you\s+remember\b|\bremind\s+me\b`),
+}
+
+// smalltalkPattern matches a whole question that is only a greeting,
+// thanks or a question about the assistant
+var smalltalkPattern = regexp.MustCompile(`^(?:hi|hello|hey|good\s+(?:morning|afternoon|evening)|thanks|thank\s+you|thx|bye|goodbye|how\s+are\s+you|who\s+are\s+you|what\s+can\s+you\s+do|help)(?:\s+(?:there|so\s+much|a\s+lot|again))?$`)
+
+// planPattern matches trip planning questions
+var planPattern = regexp.MustCompile(`\b(?:plan|planning|itinerary|trip|vacation|holiday|getaway)\b`)
+
+// tripLengthPattern matches trip lengths such as "5 days", "3-night" or
+// "a week"
+var tripLengthPattern = regexp.MustCompile(`\b(\d+|a|one)[\s-]*(day|night|week)s?\b`)
+
+// offTopicWords are subjects the data has nothing about
+var offTopicWords = map[string]bool{
+	"weather": true, "forecast": true, "recipe": true, "recipes": true, "joke": true,
+	"poem": true, "news": true, "stock": true, "stocks": true, "sports": true,
+	"score": true, "movie": true, "movies": true, "song": true, "code": true,
+	"program": true, "programming": true, "translate": true, "president": true,
+}
+
+// RuleClassifier classifies questions with patterns and the analysis of
+// the query, without an LLM
+type RuleClassifier struct{}
+
+// Name identifies the classifier
+func (RuleClassifier) Name() string {
+	return ClassifierRules
+}
+
+// Classify checks, in order, for smalltalk, questions about the
+// conversation, aggregates, comparisons, trip plans and off-topic
+// questions; anything else is a lookup
+func (RuleClassifier) Classify(ctx context.Context, req IntentRequest) (Intent, error) {
+	lower := strings.ToLower(strings.TrimSpace(req.Query))
+	plain := strings.Join(strings.FieldsFunc(lower, func(r rune) bool {
+		return strings.ContainsRune(" \t!?.,", r)
+	}), " ")
+
+	if smalltalkPattern.MatchString(plain) {
+		return IntentSmalltalk, nil
+	}
+	for _, pattern := range historyPatterns {
+		if pattern.MatchString(lower) {
+			return IntentHistory, nil
+		}
+	}
+
+	search := strings.ToLower(req.Search)
+	if _, ok := PlanQuery(req.Search); ok && len(req.Locations) != 1 {
+		return IntentAggregate, nil
+	}
+	if len(req.Locations) > 1 {
+		return IntentCompare, nil
+	}
+	if planPattern.MatchString(search) {
+		return IntentPlan, nil
+	}
+
+	// Off-topic subjects stay out of scope even when they name a location,
+	// as in "what's the weather in Texas?"
+	if len(req.Analysis.Topics) == 0 {
+		for _, term := range req.Analysis.Terms {
+			if offTopicWords[term] {
+				return IntentOutOfScope, nil
+			}
+		}
+	}
+	return IntentLookup, nil
+}
+
+// LLMClassifier asks the LLM for the intent of the question
+type LLMClassifier struct {
+	Provider LLMProvider
+}
+
+// Name identifies the classifier
+func (c *LLMClassifier) Name() string {
+	return ClassifierLLM
+}
+
+// Classify sends the question and the list of intents and returns the
+// first intent named in the reply
+func (c *LLMClassifier) Classify(ctx context.Context, req IntentRequest) (Intent, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Question: %s\n", req.Query)
+	if req.Search != req.Query {
+		fmt.Fprintf(&prompt, "Standalone form: %s\n", req.Search)
+	}
+	prompt.WriteString("\nIntents:\n" +
+		"history: asks about the conversation so far, such as the locations asked about\n" +
+		"lookup: asks about the tax rates, attractions or travel costs of one location\n" +
+		"compare: compares several locations\n" +
+		"aggregate: asks for the highest, lowest, average or a filter over all locations\n" +
+		"plan: plans a trip to a location\n" +
+		"smalltalk: greetings, thanks or questions about the assistant\n" +
+		"out-of-scope: anything else\n" +
+		"\nReply only with the intent.")
+
+	reply, err := c.Provider.ChatCompletion(ctx, []ChatMessage{
+		{Role: RoleSystem, Content: "You classify questions sent to a travel and tax information assistant."},
+		{Role: RoleUser, Content: prompt.String()},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(reply), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+	for _, word := range words {
+		if slices.Contains(intents, Intent(word)) {
+			return Intent(word), nil
+		}
+	}
+	return "", fmt.Errorf("unknown intent in reply %q", strings.TrimSpace(reply))
+}
+
+// classifyQuery decides the intent with the session's classifier, falling
+// back to the rules when it fails, and logs the result
+func classifyQuery(ctx context.Context, session *Session, req IntentRequest) Intent {
+	classifier := session.Classifier
+	if classifier == nil {
+		classifier = RuleClassifier{}
+	}
+
+	intent, err := classifier.Classify(ctx, req)
+	name := classifier.Name()
+	if err != nil {
+		session.logf("intent classification with %s failed: %v", name, err)
+		intent, _ = RuleClassifier{}.Classify(ctx, req)
+		name = ClassifierRules
+	}
+	session.logf("classified %q as %s (%s)", req.Query, intent, name)
+	return intent
+}
+
+// smalltalkResponse answers greetings and thanks with what can be asked
+func smalltalkResponse(query string) string {
+	const usage = "Ask me about tax rates, tourist attractions or travel costs, for example 'Tell me about California'."
+	lower := strings.ToLower(query)
+	switch {
+	case strings.Contains(lower, "thank") || strings.Contains(lower, "thx"):
+		return "You're welcome! " + usage
+	case strings.Contains(lower, "bye"):
+		return "Goodbye! Type 'exit' to leave the session."
+	default:
+		return "Hello! " + usage
+	}
+}
+
+// outOfScopeResponse explains what the data covers
+func outOfScopeResponse(resolver *LocationResolver) string {
+	response := "I can only answer questions about tax rates, tourist attractions and travel costs."
+	if locations := resolver.Locations(); len(locations) > 0 {
+		if len(locations) > 3 {
+			locations = locations[:3]
+		}
+		response += fmt.Sprintf(" Try asking about %s.", joinOr(locations))
+	}
+	return response
+}
+
+// tripLength returns the length of a trip mentioned in the query and
+// whether it is counted in nights. Weeks are counted in days; rates such
+// as "$250 a day" or "per night" are not lengths.
+func tripLength(query string) (int, bool, bool) {
+	for _, match := range tripLengthPattern.FindAllStringSubmatch(strings.ToLower(query), -1) {
+		count, unit := match[1], match[2]
+		if count == "a" && unit != "week" {
+			continue
+		}
+		n := 1
+		if count != "a" && count != "one" {
+			var err error
+			if n, err = strconv.Atoi(count); err != nil || n <= 0 {
+				continue
+			}
+		}
+		if unit == "week" {
+			n *= 7
+		}
+		return n, unit == "night", true
+	}
+	return 0, false, false
+}
+
+// tripEstimate estimates the cost of a trip of the length mentioned in the
+// query from the location's daily cost, or its average hotel cost for
+// lengths counted in nights
+func tripEstimate(corpus *Corpus, location, query string) (string, bool) {
+	length, nights, ok := tripLength(query)
+	if !ok {
+		return "", false
+	}
+	field, unit, period := "daily_cost", "day", "cost"
+	if nights {
+		field, unit, period = "hotel_avg", "night", "hotel cost"
+	}
+	records, _ := corpus.Store.ByLocation(location)
+	merged, _ := mergeLocationRecords(records)
+	for _, record := range merged {
+		if record.DataType != "cost" {
+			continue
+		}
+		if rate, ok := record.Number(field); ok {
+			return fmt.Sprintf("Estimated %s of a %d-%s trip to %s: %s (%s per %s)",
+				period, length, unit, location, formatNumeric(rate.Value*float64(length), UnitCurrency),
+				formatNumeric(rate.Value, UnitCurrency), unit), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Chat roles understood by every LLMProvider
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ChatMessage is a single provider-neutral chat message
+type ChatMessage struct {
+	Role    string
+	Content string
+}
+
+// Capabilities describes what a provider's model supports
+type Capabilities struct {
+	MaxContextTokens int
+	SystemPrompt     bool
+	Offline          bool
+}
+
+// LLMProvider is implemented by every chat completion backend
+type LLMProvider interface {
+	ChatCompletion(ctx context.Context, messages []ChatMessage) (string, error)
+	ModelName() string
+	Capabilities() Capabilities
+}
+
+// ProviderConfig selects and configures an LLMProvider
+type ProviderConfig struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+}
+
+const (
+	ProviderNone             = "none"
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderFake             = "fake"
+)
+
+// OpenAIProvider talks to the OpenAI chat completion API or any server
+// exposing the same API under a different base URL
+type OpenAIProvider struct {
+	client *openai.Client
+	model  string
+	caps   Capabilities
+}
+
+// NewOpenAIProvider wraps an existing OpenAI client
+func NewOpenAIProvider(client *openai.Client, model string) *OpenAIProvider {
+	if model == "" {
+		model = openai.GPT3Dot5Turbo
+	}
+	return &OpenAIProvider{
+		client: client,
+		model:  model,
+		caps:   Capabilities{MaxContextTokens: 16385, SystemPrompt: true},
+	}
+}
+
+// NewOpenAICompatibleProvider creates a provider for local servers such as
+// llama.cpp, vLLM or Ollama that implement the OpenAI chat API
+func NewOpenAICompatibleProvider(baseURL, apiKey, model string) (*OpenAIProvider, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("base URL is required for the %s provider", ProviderOpenAICompatible)
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model is required for the %s provider", ProviderOpenAICompatible)
+	}
+
+	config := openai.DefaultConfig(apiKey)
+	config.BaseURL = strings.TrimRight(baseURL, "/")
+
+	return &OpenAIProvider{
+		client: openai.NewClientWithConfig(config),
+		model:  model,
+		caps:   Capabilities{MaxContextTokens: 4096, SystemPrompt: true},
+	}, nil
+}
+
+// ChatCompletion sends the messages and returns the first choice
+func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []ChatMessage) (string, error) {
+	request := openai.ChatCompletionRequest{Model: p.model}
+	for _, message := range messages {
+		request.Messages = append(request.Messages, openai.ChatCompletionMessage{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+
+	resp, err := p.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("chat completion failed: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("chat completion returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// ModelName returns the model used for completions
+func (p *OpenAIProvider) ModelName() string {
+	return p.model
+}
+
+// Capabilities returns the model capabilities
+func (p *OpenAIProvider) Capabilities() Capabilities {
+	return p.caps
+}
+
+// FakeProvider is a deterministic provider for tests and offline runs.
+// It returns the configured responses in order, falling back to echoing
+// the last user message. It is safe for concurrent use; read Calls once
+// the calls have returned.
+type FakeProvider struct {
+	Responses []string
+	Err       error
+	Calls     [][]ChatMessage
+
+	mu sync.Mutex
+}
+
+// NewFakeProvider creates a fake provider returning the given responses
+func NewFakeProvider(responses ...string) *FakeProvider {
+	return &FakeProvider{Responses: responses}
+}
+
+// ChatCompletion records the call and returns the next canned response
+func (p *FakeProvider) ChatCompletion(ctx context.Context, messages []ChatMessage) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Calls = append(p.Calls, messages)
+	if p.Err != nil {
+		return "", p.Err
+	}
+
+	if len(p.Responses) > 0 {
+		response := p.Responses[0]
+		if len(p.Responses) > 1 {
+			p.Responses = p.Responses[1:]
+		}
+		return response, nil
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content, nil
+		}
+	}
+	return "", nil
+}
+
+// ModelName returns a fixed model name
+func (p *FakeProvider) ModelName() string {
+	return "fake"
+}
+
+// Capabilities returns the fake model capabilities
+func (p *FakeProvider) Capabilities() Capabilities {
+	return Capabilities{MaxContextTokens: 4096, SystemPrompt: true, Offline: true}
+}
+
+// providerConfigFromEnv fills empty config fields from the environment
+func providerConfigFromEnv(cfg ProviderConfig) ProviderConfig {
+	if cfg.Provider == "" {
+		cfg.Provider = os.Getenv("GORAGAGENT_PROVIDER")
+	}
+	if cfg.Model == "" {
+		cfg.Model = os.Getenv("GORAGAGENT_MODEL")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = os.Getenv("GORAGAGENT_BASE_URL")
+	}
+	if cfg.APIKey == "" {
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+	}
+
+	// Keep the historical behaviour: an API key alone enables OpenAI
+	if cfg.Provider == "" {
+		switch {
+		case cfg.BaseURL != "":
+			cfg.Provider = ProviderOpenAICompatible
+		case cfg.APIKey != "":
+			cfg.Provider = ProviderOpenAI
+		default:
+			cfg.Provider = ProviderNone
+		}
+	}
+	return cfg
+}
+
+// NewProvider builds the provider selected by the config. A nil provider
+// with a nil error means basic mode without AI-enhanced responses.
+func NewProvider(cfg ProviderConfig) (LLMProvider, error) {
+	cfg = providerConfigFromEnv(cfg)
+
+	switch strings.ToLower(cfg.Provider) {
+	case ProviderNone:
+		return nil, nil
+	case ProviderOpenAI:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("OPENAI_API_KEY is required for the %s provider", ProviderOpenAI)
+		}
+		return NewOpenAIProvider(openai.NewClient(cfg.APIKey), cfg.Model), nil
+	case ProviderOpenAICompatible:
+		// Return a nil interface on error, not a nil *OpenAIProvider that
+		// callers would take for a configured provider
+		provider, err := NewOpenAICompatibleProvider(cfg.BaseURL, cfg.APIKey, cfg.Model)
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	case ProviderFake:
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,404 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Loader parses one data file format into Records, checking every row
+// against the schema
+type Loader func(r io.Reader, source DataSource, schema *Schema) ([]Record, error)
+
+// loaders holds the registered loaders keyed by file extension
+var loaders = map[string]Loader{
+	".csv":   loadCSV,
+	".json":  loadJSON,
+	".jsonl": loadJSONL,
+	".yaml":  loadYAML,
+	".yml":   loadYAML,
+}
+
+// RegisterLoader adds or replaces the loader of a file extension such as ".tsv"
+func RegisterLoader(extension string, loader Loader) {
+	loaders[strings.ToLower(extension)] = loader
+}
+
+// loaderExtensions returns the registered extensions in alphabetical order
+func loaderExtensions() []string {
+	extensions := make([]string, 0, len(loaders))
+	for extension := range loaders {
+		extensions = append(extensions, extension)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// LoadSource reads a data source with the loader registered for its
+// extension. The key column defaults to "location" and the source column
+// to "source"; CSV files without them use their first and last columns.
+func LoadSource(source DataSource) ([]Record, error) {
+	if err := validatePath(source.Path, loaderExtensions()...); err != nil {
+		return nil, err
+	}
+	loader := loaders[strings.ToLower(filepath.Ext(source.Path))]
+
+	schema := schemaFor(source)
+	if err := schema.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schema for %s: %v", source.Path, err)
+	}
+
+	file, err := os.Open(source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+
+	// The newest merge policy falls back to the file's modification time
+	// for records without an effective date
+	records, err := loader(file, source, schema)
+	for i := range records {
+		records[i].Modified = info.ModTime()
+	}
+	return records, err
+}
+
+// RowError is a problem with one row of a data file, and with one of its
+// columns when Column is set
+type RowError struct {
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("invalid data at row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("invalid data at row %d, column %s: %v", e.Row, e.Column, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// rowError wraps an error found in a row, taking the column from column
+// errors
+func rowError(row int, err error) *RowError {
+	var column *columnError
+	if errors.As(err, &column) {
+		return &RowError{Row: row, Column: column.column, Err: column.err}
+	}
+	return &RowError{Row: row, Err: err}
+}
+
+// LoadErrors collects the errors of every bad row of a data file. Loaders
+// keep reading past bad rows so all of them are reported at once.
+type LoadErrors []error
+
+func (e LoadErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e[0], len(e)-1)
+}
+
+func (e LoadErrors) Unwrap() []error {
+	return e
+}
+
+// loadResult returns the records loaded, failing when any row was bad
+func loadResult(records []Record, errs LoadErrors) ([]Record, error) {
+	if len(errs) > 0 {
+		return records, errs
+	}
+	return records, nil
+}
+
+// newRecord builds a Record from the named fields of one row. Fields are
+// trimmed so answers never show the padding around them.
+func newRecord(source DataSource, schema *Schema, row int, names, fields []string, keyColumn, sourceColumn string) (Record, error) {
+	if err := validateRecord(fields); err != nil {
+		return Record{}, rowError(row, err)
+	}
+
+	record := Record{DataType: source.DataType, Values: make(map[string]string)}
+	var effective, validUntil string
+	for i, name := range names {
+		field := strings.TrimSpace(fields[i])
+		switch {
+		case strings.EqualFold(name, keyColumn):
+			record.Location = field
+		case strings.EqualFold(name, sourceColumn):
+			record.Source = field
+		case strings.EqualFold(name, effectiveDateColumn):
+			effective = field
+		case strings.EqualFold(name, validUntilColumn):
+			validUntil = field
+		default:
+			record.Values[name] = field
+		}
+	}
+
+	if record.Location == "" {
+		return Record{}, &RowError{Row: row, Column: keyColumn, Err: errors.New("missing location")}
+	}
+
+	if err := record.setDates(effective, validUntil); err != nil {
+		return Record{}, rowError(row, err)
+	}
+
+	numbers, err := schema.parseValues(record.Values)
+	if err != nil {
+		return Record{}, rowError(row, err)
+	}
+	record.Numbers = numbers
+	return record, nil
+}
+
+// loadCSV reads a CSV file with a header row
+func loadCSV(r io.Reader, source DataSource, schema *Schema) ([]Record, error) {
+	reader := csv.NewReader(r)
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV file is empty or missing data rows")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %v", err)
+	}
+	if err := schema.checkHeaders(headers); err != nil {
+		return nil, fmt.Errorf("invalid data in %s: %v", source.Path, err)
+	}
+
+	keyIndex := columnIndex(headers, schema.keyColumn(source))
+	sourceIndex := columnIndex(headers, schema.sourceColumn())
+	if keyIndex < 0 {
+		if source.KeyColumn != "" || (schema != nil && schema.KeyColumn != "") {
+			return nil, fmt.Errorf("invalid data in %s: missing key column %q", source.Path, schema.keyColumn(source))
+		}
+		keyIndex = 0
+	}
+	if sourceIndex < 0 {
+		sourceIndex = len(headers) - 1
+	}
+
+	var result []Record
+	var errs LoadErrors
+	for row := 2; ; row++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, rowError(row, err))
+			continue
+		}
+
+		record, err := newRecord(source, schema, row, headers, fields, headers[keyIndex], headers[sourceIndex])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, record)
+	}
+
+	if len(result) == 0 && len(errs) == 0 {
+		return nil, fmt.Errorf("CSV file is empty or missing data rows")
+	}
+	return loadResult(result, errs)
+}
+
+// columnIndex returns the position of a header, or -1
+func columnIndex(headers []string, name string) int {
+	for i, header := range headers {
+		if strings.EqualFold(strings.TrimSpace(header), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadJSON reads a JSON array of objects, or an object with a "records" array
+func loadJSON(r io.Reader, source DataSource, schema *Schema) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON: %v", err)
+	}
+
+	var rows []map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var wrapper struct {
+			Records []map[string]any `json:"records"`
+		}
+		err = decoder.Decode(&wrapper)
+		rows = wrapper.Records
+	} else {
+		err = decoder.Decode(&rows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON: %v", err)
+	}
+	return objectRecords(rows, source, schema, nil)
+}
+
+// loadJSONL reads one JSON object per line; blank lines are skipped
+func loadJSONL(r io.Reader, source DataSource, schema *Schema) ([]Record, error) {
+	var rows []map[string]any
+	var lines []int
+	var errs LoadErrors
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		var row map[string]any
+		if err := decoder.Decode(&row); err != nil {
+			errs = append(errs, rowError(line, err))
+			continue
+		}
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading JSONL: %v", err)
+	}
+	if len(rows) == 0 && len(errs) > 0 {
+		return nil, errs
+	}
+
+	records, err := objectRecords(rows, source, schema, lines)
+	var rowErrs LoadErrors
+	if err != nil && !errors.As(err, &rowErrs) {
+		return nil, err
+	}
+
+	// Report undecodable and invalid lines in file order; both are row errors
+	errs = append(errs, rowErrs...)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].(*RowError).Row < errs[j].(*RowError).Row
+	})
+	return loadResult(records, errs)
+}
+
+// loadYAML reads a YAML list of mappings, or a mapping with a "records" list
+func loadYAML(r io.Reader, source DataSource, schema *Schema) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading YAML: %v", err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("error reading YAML: %v", err)
+	}
+
+	var rows []map[string]any
+	if len(document.Content) > 0 && document.Content[0].Kind == yaml.MappingNode {
+		var wrapper struct {
+			Records []map[string]any `yaml:"records"`
+		}
+		err = document.Decode(&wrapper)
+		rows = wrapper.Records
+	} else {
+		err = document.Decode(&rows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading YAML: %v", err)
+	}
+	return objectRecords(rows, source, schema, nil)
+}
+
+// objectRecords converts decoded objects into Records. Rows are numbered
+// from 1 unless their line numbers are given.
+func objectRecords(rows []map[string]any, source DataSource, schema *Schema, lines []int) ([]Record, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("data file is empty or missing records")
+	}
+
+	keyColumn, sourceColumn := schema.keyColumn(source), schema.sourceColumn()
+	var result []Record
+	var errs LoadErrors
+rows:
+	for i, row := range rows {
+		number := i + 1
+		if lines != nil {
+			number = lines[i]
+		}
+
+		names := make([]string, 0, len(row))
+		for name := range row {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if err := validateRecord(names); err != nil {
+			errs = append(errs, rowError(number, err))
+			continue
+		}
+
+		fields := make([]string, len(names))
+		for j, name := range names {
+			value, err := scalarString(row[name])
+			if err != nil {
+				errs = append(errs, &RowError{Row: number, Column: name, Err: err})
+				continue rows
+			}
+			fields[j] = value
+		}
+
+		record, err := newRecord(source, schema, number, names, fields, keyColumn, sourceColumn)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, record)
+	}
+	return loadResult(result, errs)
+}
+
+// scalarString renders a decoded JSON or YAML scalar as a field value
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02"), nil
+		}
+		return v.Format(time.RFC3339), nil
+	default:
+		return "", fmt.Errorf("nested values are not supported")
+	}
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Merge policies deciding which record wins when sources disagree
+const (
+	MergeNewest   = "newest"
+	MergePriority = "priority"
+	MergeShowAll  = "all"
+)
+
+// MergeConfig selects the merge policy. Priority lists record sources,
+// most trusted first, for the priority policy. AsOf is the date records
+// must be in effect at, today when zero.
+type MergeConfig struct {
+	Policy   string
+	Priority []string
+	AsOf     time.Time
+}
+
+// DefaultMergeConfig keeps the newest value of every field
+func DefaultMergeConfig() MergeConfig {
+	return MergeConfig{Policy: MergeNewest}
+}
+
+// Validate reports an unknown policy
+func (c MergeConfig) Validate() error {
+	switch strings.ToLower(c.Policy) {
+	case MergeNewest, MergePriority, MergeShowAll:
+		return nil
+	default:
+		return fmt.Errorf("unknown merge policy %q: must be %s", c.Policy, joinOr([]string{MergeNewest, MergePriority, MergeShowAll}))
+	}
+}
+
+// SourcedValue is one source's value for a conflicting field
+type SourcedValue struct {
+	Value  string
+	Source string
+}
+
+// Conflict records sources disagreeing on a field of a location's data
+type Conflict struct {
+	Location string
+	DataType string
+	Field    string
+	Values   []SourcedValue
+	// Chosen is the source whose value of the field is shown, empty when
+	// all are shown
+	Chosen string
+	Policy string
+}
+
+// MergeResult holds the records left after merging and the conflicts found
+type MergeResult struct {
+	Records   []Record
+	Conflicts []Conflict
+}
+
+// MergeRecords merges the records that share a location and data type.
+// Records not in effect, or superseded by a later effective date, are
+// dropped along with exact duplicates. The fields of the rest are merged
+// one by one: every field any record gives is kept, and records
+// disagreeing on a field are resolved by the policy. Newest takes the
+// value of the record with the latest effective date, or of the file
+// changed last when undated, priority the value from the most trusted
+// source and all keeps every record. Each kept record holds the fields
+// whose value it supplies, so values stay attributed to their source, and
+// records supplying none are dropped. Documents are passed through
+// unchanged. Record order is preserved.
+func MergeRecords(records []Record, config MergeConfig) (MergeResult, error) {
+	if err := config.Validate(); err != nil {
+		return MergeResult{}, err
+	}
+	policy := strings.ToLower(config.Policy)
+	asOf := config.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+
+	type key struct{ location, dataType string }
+	groups := make(map[key][]int)
+	var order []key
+	for i, record := range records {
+		if isDocument(record) {
+			continue
+		}
+		k := key{record.Location, record.DataType}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	// keep holds the kept records, with the fields they supply when only
+	// some of their fields are kept
+	keep := make(map[int]map[string]bool)
+	var result MergeResult
+	for _, k := range order {
+		group := distinctRecords(records, currentRecords(records, groups[k], asOf))
+		owners := make(map[string]int)
+		if len(group) == 1 || policy == MergeShowAll {
+			for _, i := range group {
+				keep[i] = nil
+			}
+		} else {
+			owners = fieldOwners(records, rankRecords(records, group, policy, config.Priority))
+			for field, i := range owners {
+				if keep[i] == nil {
+					keep[i] = make(map[string]bool)
+				}
+				keep[i][field] = true
+			}
+		}
+
+		for _, field := range conflictingFields(records, group) {
+			conflict := Conflict{Location: k.location, DataType: k.dataType, Field: field, Policy: policy}
+			for _, i := range group {
+				if value := records[i].Values[field]; value != "" {
+					conflict.Values = append(conflict.Values, SourcedValue{Value: value, Source: records[i].Source})
+				}
+			}
+			if i, ok := owners[field]; ok {
+				conflict.Chosen = records[i].Source
+			}
+			result.Conflicts = append(result.Conflicts, conflict)
+		}
+	}
+
+	for i, record := range records {
+		fields, kept := keep[i]
+		if isDocument(record) || (kept && fields == nil) {
+			result.Records = append(result.Records, record)
+		} else if kept {
+			result.Records = append(result.Records, recordFields(record, fields))
+		}
+	}
+	return result, nil
+}
+
+// recordFields returns the record holding only the given fields, or the
+// record itself when it has no others
+func recordFields(record Record, fields map[string]bool) Record {
+	if len(fields) == len(record.Values) {
+		return record
+	}
+	record.Values = maps.Clone(record.Values)
+	maps.DeleteFunc(record.Values, func(field, _ string) bool { return !fields[field] })
+	if record.Numbers != nil {
+		record.Numbers = maps.Clone(record.Numbers)
+		maps.DeleteFunc(record.Numbers, func(field string, _ NumericValue) bool { return !fields[field] })
+	}
+	return record
+}
+
+// distinctRecords drops the records repeating an earlier record's values,
+// source and dates
+func distinctRecords(records []Record, group []int) []int {
+	var distinct []int
+	for _, i := range group {
+		duplicate := false
+		for _, j := range distinct {
+			a, b := records[i], records[j]
+			if a.Source == b.Source && maps.Equal(a.Values, b.Values) &&
+				a.EffectiveDate.Equal(b.EffectiveDate) && a.ValidUntil.Equal(b.ValidUntil) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			distinct = append(distinct, i)
+		}
+	}
+	return distinct
+}
+
+// rankRecords orders a group of records by the policy, preferred first.
+// Newest ranks by effective date, falling back to when the record's file
+// last changed, and the priority policy ranks sources it does not list,
+// or lists alike, by the newest rules. Records still tied, such as two
+// from the same file, rank the one loaded last first.
+func rankRecords(records []Record, group []int, policy string, priority []string) []int {
+	rank := func(source string) int {
+		for i, trusted := range priority {
+			if strings.EqualFold(strings.TrimSpace(trusted), source) {
+				return i
+			}
+		}
+		return len(priority)
+	}
+
+	ranked := slices.Clone(group)
+	slices.Reverse(ranked)
+	slices.SortStableFunc(ranked, func(i, j int) int {
+		if policy == MergePriority {
+			if order := rank(records[i].Source) - rank(records[j].Source); order != 0 {
+				return order
+			}
+		}
+		return recordDate(records[j]).Compare(recordDate(records[i]))
+	})
+	return ranked
+}
+
+// recordDate is the date a record is considered current from: its
+// effective date, or when its file last changed
+func recordDate(record Record) time.Time {
+	if !record.EffectiveDate.IsZero() {
+		return record.EffectiveDate
+	}
+	return record.Modified
+}
+
+// fieldOwners maps every field the ranked records give a value for to the
+// first record giving it
+func fieldOwners(records []Record, ranked []int) map[string]int {
+	owners := make(map[string]int)
+	for _, i := range ranked {
+		for field, value := range records[i].Values {
+			if _, ok := owners[field]; !ok && value != "" {
+				owners[field] = i
+			}
+		}
+	}
+	return owners
+}
+
+// conflictingFields lists the fields, in first-seen order, that records of
+// the group give different values for. Numeric values are compared by
+// value so "6.25%" and "6.250%" agree.
+func conflictingFields(records []Record, group []int) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, i := range group {
+		for _, field := range slices.Sorted(maps.Keys(records[i].Values)) {
+			if seen[field] {
+				continue
+			}
+			seen[field] = true
+
+			var first string
+			for _, j := range group {
+				value := records[j].Values[field]
+				if value == "" {
+					continue
+				}
+				if first == "" {
+					first = value
+				} else if !sameValue(first, value) {
+					fields = append(fields, field)
+					break
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// sameValue reports whether two raw values agree
+func sameValue(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	x, okA := ParseNumeric(a)
+	y, okB := ParseNumeric(b)
+	return okA && okB && x == y
+}
+
+// formatConflict describes a conflict and how it was resolved
+func formatConflict(conflict Conflict) string {
+	values := make([]string, len(conflict.Values))
+	for i, value := range conflict.Values {
+		values[i] = fmt.Sprintf("%s (%s)", value.Value, value.Source)
+	}
+	note := fmt.Sprintf("Note: sources disagree on %s for %s: %s.",
+		conflict.Field, conflict.Location, strings.Join(values, " vs "))
+	if conflict.Chosen == "" {
+		return note + " All values are shown."
+	}
+	return fmt.Sprintf("%s Showing the value from %s (%s policy).", note, conflict.Chosen, conflict.Policy)
+}
+
+// mergeWithConfig merges records with the session's merge policy
+func mergeWithConfig(records []Record) MergeResult {
+	result, err := MergeRecords(records, mergeConfig)
+	if err != nil {
+		// Invalid policies are reported at startup
+		result, _ = MergeRecords(records, DefaultMergeConfig())
+	}
+	return result
+}
+
+// mergedRecords returns the records left after merging with the session's
+// merge policy, so rankings, comparisons and combined rates show the same
+// values as single-location answers
+func mergedRecords(records []Record) []Record {
+	return mergeWithConfig(records).Records
+}
+
+// mergeLocationRecords merges the records of a location with the session's
+// merge policy, returning the records to show and notes on their conflicts
+func mergeLocationRecords(records []Record) ([]Record, []string) {
+	result := mergeWithConfig(records)
+	notes := make([]string, len(result.Conflicts))
+	for i, conflict := range result.Conflicts {
+		notes[i] = formatConflict(conflict)
+	}
+	return result.Records, notes
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+func init() {
+	RegisterLoader(".pdf", loadPDF)
+}
+
+// loadPDF extracts the text of every page and chunks it like a plain-text
+// document. Chunks keep the page and lines they came from and use the file
+// name as their source, linking them to the rows that cite the PDF.
+func loadPDF(r io.Reader, source DataSource, schema *Schema) (records []Record, err error) {
+	// The PDF parser panics on some malformed files; report those like any
+	// other unreadable file rather than crashing every command loading them
+	defer func() {
+		if p := recover(); p != nil {
+			records, err = nil, fmt.Errorf("error reading PDF %s: %v", source.Path, p)
+		}
+	}()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF %s: %v", source.Path, err)
+	}
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF %s: %v", source.Path, err)
+	}
+
+	var sections []documentSection
+	for n := 1; n <= reader.NumPage(); n++ {
+		page := reader.Page(n)
+		if page.V.IsNull() {
+			continue
+		}
+		lines, err := pageLines(page)
+		if err != nil {
+			return nil, fmt.Errorf("error reading PDF page %d: %v", n, err)
+		}
+
+		section := documentSection{Page: n}
+		for i, line := range lines {
+			for _, word := range strings.Fields(line) {
+				section.Words = append(section.Words, documentWord{Text: word, Line: i + 1})
+			}
+		}
+		if len(section.Words) > 0 {
+			sections = append(sections, section)
+		}
+	}
+
+	records = documentRecords(source, sections)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("PDF has no extractable text")
+	}
+	return records, nil
+}
+
+// pageLines rebuilds the lines of a page from its positioned glyphs, top
+// to bottom. A gap wider than a fraction of the font size becomes a space.
+func pageLines(page pdf.Page) (lines []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed page content: %v", r)
+		}
+	}()
+
+	texts := page.Content().Text
+	sort.SliceStable(texts, func(i, j int) bool {
+		return texts[i].Y > texts[j].Y
+	})
+
+	var rows [][]pdf.Text
+	for _, text := range texts {
+		last := len(rows) - 1
+		if last < 0 || math.Abs(rows[last][0].Y-text.Y) > max(text.FontSize, 1)/2 {
+			rows = append(rows, nil)
+			last++
+		}
+		rows[last] = append(rows[last], text)
+	}
+
+	for _, row := range rows {
+		sort.SliceStable(row, func(i, j int) bool {
+			return row[i].X < row[j].X
+		})
+
+		var b strings.Builder
+		for i, text := range row {
+			if i > 0 {
+				prev := row[i-1]
+				if text.X-(prev.X+prev.W) > max(text.FontSize, 1)*0.15 {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(strings.ReplaceAll(text.S, "\x00", ""))
+		}
+		lines = append(lines, b.String())
+	}
+	return lines, nil
+}
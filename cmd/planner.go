@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Units of numeric values
+const (
+	UnitPercent  = "%"
+	UnitCurrency = "$"
+)
+
+// NumericValue is a number parsed from a CSV value such as "7.25%" or "$350"
+type NumericValue struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// numericPattern matches numbers whose commas separate groups of three
+// digits, such as "1,200", or mark decimals, such as "2,1%", so "1,2,3"
+// is not a number
+var numericPattern = regexp.MustCompile(`^\$?\s*(?:(-?(?:\d{1,3}(?:,\d{3})+|\d+)(?:\.\d+)?|-?\.\d+)|(-?\d+,\d{1,2}))\s*(%|/\s*\w+)?$`)
+
+// ParseNumeric parses percentages, currency amounts and plain numbers.
+// Thousands separators, decimal commas and per-unit suffixes such as
+// "/day" are accepted.
+func ParseNumeric(raw string) (NumericValue, bool) {
+	raw = strings.TrimSpace(raw)
+	match := numericPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return NumericValue{}, false
+	}
+
+	number := strings.ReplaceAll(match[1], ",", "")
+	if match[2] != "" {
+		number = strings.Replace(match[2], ",", ".", 1)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return NumericValue{}, false
+	}
+
+	result := NumericValue{Value: value}
+	switch {
+	case match[3] == "%":
+		result.Unit = UnitPercent
+	case strings.HasPrefix(raw, "$"):
+		result.Unit = UnitCurrency
+	}
+	return result, true
+}
+
+// numericField describes a numeric column the planner can reason about
+type numericField struct {
+	Label string
+	Unit  string
+}
+
+var numericFields = map[string]numericField{
+	"tax_rate":   {Label: "tax rate", Unit: UnitPercent},
+	"daily_cost": {Label: "daily cost", Unit: UnitCurrency},
+	"hotel_avg":  {Label: "hotel cost per night", Unit: UnitCurrency},
+	"food_avg":   {Label: "food cost per day", Unit: UnitCurrency},
+}
+
+// formatNumeric renders a value in the field's unit
+func formatNumeric(value float64, unit string) string {
+	switch unit {
+	case UnitPercent:
+		return strconv.FormatFloat(value, 'f', 2, 64) + "%"
+	case UnitCurrency:
+		if value == float64(int64(value)) {
+			return fmt.Sprintf("$%d", int64(value))
+		}
+		return fmt.Sprintf("$%.2f", value)
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
+
+// Plan kinds recognised by the query planner
+const (
+	PlanSuperlative = "superlative"
+	PlanFilter      = "filter"
+	PlanAggregate   = "aggregate"
+)
+
+// QueryPlan is a structured query over the numeric columns of all records
+type QueryPlan struct {
+	Kind      string
+	Field     string
+	Ascending bool    // superlative: lowest first
+	Operator  string  // filter: "<", "<=", ">" or ">="
+	Threshold float64 // filter: value compared against
+	Aggregate string  // aggregate: "average" or "total"
+	Scope     string  // "state", "county" or empty for every location
+}
+
+// fieldKeywords map query words to numeric fields, most specific first
+var fieldKeywords = []struct {
+	Words []string
+	Field string
+}{
+	{Words: []string{"hotel", "hotels", "lodging", "accommodation", "night"}, Field: "hotel_avg"},
+	{Words: []string{"food", "meal", "meals", "eat", "eating", "dining"}, Field: "food_avg"},
+	{Words: []string{"tax", "taxes"}, Field: "tax_rate"},
+	{Words: []string{"daily", "day", "cost", "costs", "expensive", "cheap", "cheaper", "cheapest",
+		"budget", "spend", "price", "prices", "priciest", "affordable"}, Field: "daily_cost"},
+}
+
+var (
+	ascendingWords  = []string{"lowest", "cheapest", "least", "minimum", "smallest", "cheaper", "lower", "most affordable"}
+	descendingWords = []string{"highest", "most expensive", "priciest", "maximum", "largest", "biggest", "more expensive", "higher"}
+	aggregateWords  = map[string]string{
+		"average": "average", "avg": "average", "mean": "average", "typical": "average",
+		"total": "total", "sum": "total", "combined": "total",
+	}
+	filterPattern = regexp.MustCompile(`(under|below|less than|cheaper than|lower than|at most|up to|over|above|more than|greater than|higher than|more expensive than|at least)\s+\$?((?:\d{1,3}(?:,\d{3})+|\d+)(?:\.\d+)?)`)
+)
+
+// containsPhrase reports whether the phrase occurs as whole words
+func containsPhrase(words []string, phrase string) bool {
+	return strings.Contains(" "+strings.Join(words, " ")+" ", " "+phrase+" ")
+}
+
+// PlanQuery recognises superlative, filter and aggregate questions such as
+// "which state has the lowest tax rate?", "places under $300/day" or
+// "average hotel cost"
+func PlanQuery(query string) (QueryPlan, bool) {
+	plan, ok := planQuery(query)
+	if !ok {
+		return plan, false
+	}
+
+	// "which state ..." and "which county ..." restrict the locations compared
+	for _, word := range nameWords(query) {
+		switch word {
+		case "state", "states":
+			plan.Scope = "state"
+		case "county", "counties":
+			plan.Scope = "county"
+		}
+	}
+	return plan, true
+}
+
+func planQuery(query string) (QueryPlan, bool) {
+	lower := strings.ToLower(query)
+	words := nameWords(lower)
+
+	field := ""
+	for _, keywords := range fieldKeywords {
+		for _, word := range words {
+			for _, keyword := range keywords.Words {
+				if word == keyword {
+					field = keywords.Field
+				}
+			}
+		}
+		if field != "" {
+			break
+		}
+	}
+
+	if match := filterPattern.FindStringSubmatch(lower); match != nil {
+		threshold, err := strconv.ParseFloat(strings.ReplaceAll(match[2], ",", ""), 64)
+		if err == nil {
+			if field == "" {
+				field = "daily_cost"
+			}
+			return QueryPlan{Kind: PlanFilter, Field: field, Operator: filterOperator(match[1]), Threshold: threshold}, true
+		}
+	}
+
+	for _, word := range words {
+		if aggregate, ok := aggregateWords[word]; ok && field != "" {
+			return QueryPlan{Kind: PlanAggregate, Field: field, Aggregate: aggregate}, true
+		}
+	}
+
+	for _, phrase := range descendingWords {
+		if containsPhrase(words, phrase) && field != "" {
+			return QueryPlan{Kind: PlanSuperlative, Field: field}, true
+		}
+	}
+	for _, phrase := range ascendingWords {
+		if containsPhrase(words, phrase) {
+			if field == "" {
+				field = "daily_cost"
+			}
+			return QueryPlan{Kind: PlanSuperlative, Field: field, Ascending: true}, true
+		}
+	}
+
+	return QueryPlan{}, false
+}
+
+func filterOperator(phrase string) string {
+	switch phrase {
+	case "at most", "up to":
+		return "<="
+	case "at least":
+		return ">="
+	case "over", "above", "more than", "greater than", "higher than", "more expensive than":
+		return ">"
+	default:
+		return "<"
+	}
+}
+
+// numericEntry is one location's value for the planned field
+type numericEntry struct {
+	Location string
+	Value    float64
+	Source   string
+}
+
+// collect returns the first parsable value of the field per location in
+// the plan's scope. County tax rates are added to their state's rate, so
+// tax rate plans naming no scope only compare top-level locations.
+func (p QueryPlan) collect(records []Record) []numericEntry {
+	hierarchy := NewLocationHierarchy(records)
+	scope := p.Scope
+	if scope == "" && p.Field == "tax_rate" {
+		scope = "state"
+	}
+
+	seen := make(map[string]bool)
+	var entries []numericEntry
+	for _, record := range records {
+		if seen[record.Location] {
+			continue
+		}
+		if (scope == "state" && hierarchy.IsChild(record.Location)) ||
+			(scope == "county" && !hierarchy.IsChild(record.Location)) {
+			continue
+		}
+		value, ok := record.Number(p.Field)
+		if !ok {
+			continue
+		}
+		seen[record.Location] = true
+		entries = append(entries, numericEntry{
+			Location: record.Location,
+			Value:    value.Value,
+			Source:   record.Source,
+		})
+	}
+	return entries
+}
+
+// Execute runs the plan over the merged records and describes the result
+func (p QueryPlan) Execute(records []Record) (string, error) {
+	field, ok := numericFields[p.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown numeric field %q", p.Field)
+	}
+
+	entries := p.collect(mergedRecords(records))
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no %s data available", field.Label)
+	}
+
+	var b strings.Builder
+	switch p.Kind {
+	case PlanSuperlative:
+		sort.SliceStable(entries, func(i, j int) bool {
+			if p.Ascending {
+				return entries[i].Value < entries[j].Value
+			}
+			return entries[i].Value > entries[j].Value
+		})
+		direction := "Highest"
+		if p.Ascending {
+			direction = "Lowest"
+		}
+		best := entries[0]
+		fmt.Fprintf(&b, "%s %s: %s (%s, source: %s)", direction, field.Label, best.Location,
+			formatNumeric(best.Value, field.Unit), best.Source)
+		b.WriteString("\nRanking:")
+		for i, entry := range entries {
+			fmt.Fprintf(&b, "\n%d. %s: %s", i+1, entry.Location, formatNumeric(entry.Value, field.Unit))
+		}
+
+	case PlanFilter:
+		var matched []string
+		for _, entry := range entries {
+			if compareNumeric(entry.Value, p.Operator, p.Threshold) {
+				matched = append(matched, fmt.Sprintf("%s (%s)", entry.Location, formatNumeric(entry.Value, field.Unit)))
+			}
+		}
+		condition := fmt.Sprintf("%s %s %s", field.Label, p.Operator, formatNumeric(p.Threshold, field.Unit))
+		if len(matched) == 0 {
+			fmt.Fprintf(&b, "No locations have a %s.", condition)
+		} else {
+			fmt.Fprintf(&b, "Locations with a %s: %s", condition, strings.Join(matched, ", "))
+		}
+
+	case PlanAggregate:
+		var result float64
+		for _, entry := range entries {
+			result += entry.Value
+		}
+		if p.Aggregate == "average" {
+			result /= float64(len(entries))
+		}
+
+		var parts []string
+		for _, entry := range entries {
+			parts = append(parts, fmt.Sprintf("%s %s", entry.Location, formatNumeric(entry.Value, field.Unit)))
+		}
+		fmt.Fprintf(&b, "%s %s across %d locations: %s (%s)", strings.ToUpper(p.Aggregate[:1])+p.Aggregate[1:],
+			field.Label, len(entries), formatNumeric(result, field.Unit), strings.Join(parts, ", "))
+
+	default:
+		return "", fmt.Errorf("unknown plan kind %q", p.Kind)
+	}
+
+	return b.String(), nil
+}
+
+func compareNumeric(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	}
+	return false
+}
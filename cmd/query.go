@@ -3,42 +3,74 @@ package cmd
 import (
 	"bufio"
 	"context"
-	"encoding/csv"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
-	openai "github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
 )
 
 // Record represents information from any of our data sources
 type Record struct {
-	Location string
-	DataType string
-	Values   map[string]string
-	Source   string
+	Location string            `json:"location"`
+	DataType string            `json:"data_type"`
+	Values   map[string]string `json:"values"`
+	Source   string            `json:"source"`
+
+	// Numbers holds the typed values of the numeric schema columns
+	Numbers map[string]NumericValue `json:"numbers,omitempty"`
+
+	// EffectiveDate and ValidUntil come from the optional effective_date
+	// and valid_until columns
+	EffectiveDate time.Time `json:"effective_date,omitzero"`
+	ValidUntil    time.Time `json:"valid_until,omitzero"`
+
+	// Modified is when the record's file last changed, as of loading
+	Modified time.Time `json:"modified,omitzero"`
+
+	// Provenance and Tags are set on document chunks only
+	Provenance *Provenance `json:"provenance,omitempty"`
+	Tags       []string    `json:"tags,omitempty"`
 }
 
-// Interaction stores a user interaction
-type Interaction struct {
-	Location  string
-	Question  string
-	Timestamp time.Time
+// Number returns the typed value of a field, parsing the raw value when
+// the record was not loaded through a schema
+func (r Record) Number(field string) (NumericValue, bool) {
+	if value, ok := r.Numbers[field]; ok {
+		return value, true
+	}
+	return ParseNumeric(r.Values[field])
 }
 
 var (
 	dataFiles = map[string]string{
-		"tax":     "data/state_taxes.csv",
-		"tourist": "data/tourist_info.csv",
-		"cost":    "data/travel_costs.csv",
-	}
-	lastQuery    string
-	lastLocation string
-	interactions []Interaction // Store all interactions
-	memorySize   = 5           // Number of interactions to remember
+		"tax":        "data/state_taxes.csv",
+		"county_tax": "data/county_taxes.csv",
+		"tourist":    "data/tourist_info.csv",
+		"cost":       "data/travel_costs.csv",
+	}
+	searchTopK     = 10  // Number of records retrieved per query
+	followUpMargin = 0.1 // Score gap within which the last location is preferred
+
+	aliasesPath     string
+	providerConfig  ProviderConfig
+	embedderName    string
+	retrievalConfig = DefaultRetrievalConfig()
+	mergeConfig     = DefaultMergeConfig()
+	staleAfter      = defaultStaleAfter
+	storeName       string
+	storePath       string
+	sessionName     string
+	historyTokens   = defaultHistoryTokens
+	rewriterName    = RewriterLLM
+	classifierName  = ClassifierRules
+	debugOutput     bool
+	watchData       bool
+	watchInterval   time.Duration
 )
 
 var queryCmd = &cobra.Command{
@@ -51,67 +83,56 @@ Example: "Tell me about California"`,
 
 func init() {
 	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().StringVar(&providerConfig.Provider, "provider", "", "LLM provider: openai, openai-compatible, fake or none (default from GORAGAGENT_PROVIDER or OPENAI_API_KEY)")
+	queryCmd.Flags().StringVar(&providerConfig.Model, "model", "", "model name (default from GORAGAGENT_MODEL)")
+	queryCmd.Flags().StringVar(&aliasesPath, "aliases", "config/location_aliases.csv", "CSV file mapping location aliases to locations")
+	queryCmd.Flags().StringVar(&embedderName, "embedder", EmbedderHashing, "embedder used for vector search: hashing or openai")
+	queryCmd.Flags().StringSliceVar(&retrievalConfig.Retrievers, "retrievers", retrievalConfig.Retrievers, "retrievers fused with reciprocal rank fusion: bm25, vector")
+	queryCmd.Flags().StringVar(&retrievalConfig.Reranker, "reranker", retrievalConfig.Reranker, "reranker applied to fused results: none, features or llm")
+	queryCmd.Flags().IntVar(&retrievalConfig.RerankTopN, "rerank-top-n", retrievalConfig.RerankTopN, "number of fused results passed to the reranker")
+	queryCmd.Flags().IntVar(&retrievalConfig.FusionK, "fusion-k", retrievalConfig.FusionK, "reciprocal rank fusion constant")
+	queryCmd.Flags().StringVar(&storeName, "store", StoreMemory, "record store: memory or sqlite")
+	queryCmd.Flags().StringVar(&storePath, "store-path", "index/goragagent.db", "path to the SQLite record store")
+	queryCmd.Flags().StringVar(&mergeConfig.Policy, "merge-policy", mergeConfig.Policy, "how records from sources that disagree are merged: newest, priority or all")
+	queryCmd.Flags().StringSliceVar(&mergeConfig.Priority, "source-priority", nil, "record sources in order of trust, used by the priority merge policy")
+	queryCmd.Flags().DurationVar(&staleAfter, "stale-after", staleAfter, "age of effective dates past which answers warn that data may be out of date")
+	queryCmd.Flags().StringVar(&sessionName, "session", "", "name of a session to save and resume across runs")
+	queryCmd.Flags().StringVar(&rewriterName, "rewriter", rewriterName, "rewrites follow-ups into standalone search queries: none, heuristic or llm (heuristic without a provider)")
+	queryCmd.Flags().StringVar(&classifierName, "classifier", classifierName, "classifies the intent of each question: rules or llm")
+	queryCmd.Flags().BoolVar(&debugOutput, "debug", false, "print debugging output such as rewritten queries and intents to stderr")
+	queryCmd.Flags().IntVar(&historyTokens, "history-tokens", historyTokens, "token budget for earlier turns sent to the LLM; older turns are summarized")
+	queryCmd.Flags().BoolVar(&watchData, "watch", true, "reload data files when they change during the session")
+	queryCmd.Flags().DurationVar(&watchInterval, "watch-interval", 2*time.Second, "how often data files are checked for changes")
+	queryCmd.Flags().StringVar(&providerConfig.BaseURL, "base-url", "", "base URL of an OpenAI-compatible server (default from GORAGAGENT_BASE_URL)")
 }
 
-// LoadData reads and parses CSV files into Records
+// LoadData reads and parses a data file of any registered format into Records
 func LoadData(filePath string, dataType string) ([]Record, error) {
-	if err := validateFilePath(filePath); err != nil {
-		return nil, err
-	}
-
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("error reading CSV: %v", err)
-	}
-
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file is empty or missing data rows")
-	}
-
-	var result []Record
-	headers := records[0]
-
-	for i := 1; i < len(records); i++ {
-		record := records[i]
-		if err := validateRecord(record); err != nil {
-			return nil, fmt.Errorf("invalid data at row %d: %v", i+1, err)
-		}
-
-		values := make(map[string]string)
-		for j, header := range headers {
-			if header != "location" && header != "source" && j < len(record) {
-				values[header] = record[j]
-			}
-		}
-
-		result = append(result, Record{
-			Location: record[0],
-			DataType: dataType,
-			Values:   values,
-			Source:   record[len(record)-1],
-		})
-	}
-
-	return result, nil
+	return LoadSource(DataSource{Path: filePath, DataType: dataType})
 }
 
 // validateFilePath performs security checks on the file path
 func validateFilePath(path string) error {
+	return validatePath(path, ".csv")
+}
+
+// validatePath performs security checks on a path with one of the allowed
+// extensions
+func validatePath(path string, extensions ...string) error {
 	// Check if path is absolute
 	if filepath.IsAbs(path) {
 		return fmt.Errorf("must use relative path to data directory")
 	}
 
 	// Check file extension
-	if ext := filepath.Ext(path); ext != ".csv" {
-		return fmt.Errorf("invalid file extension: must be .csv")
+	allowed := false
+	for _, ext := range extensions {
+		if filepath.Ext(path) == ext {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("invalid file extension: must be %s", joinOr(extensions))
 	}
 
 	// Check for path traversal attempts
@@ -166,73 +187,145 @@ func validateRecord(record []string) error {
 	return nil
 }
 
-// FindRelevantInfo searches for information based on the query
-func FindRelevantInfo(query string, records []Record) (string, string) {
-	query = strings.ToLower(strings.TrimSpace(query))
+// FindRelevantInfo searches the corpus for information based on the query,
+// following up on and updating the session's conversation
+func FindRelevantInfo(session *Session, corpus *Corpus, query string) (string, string) {
+	query = strings.TrimSpace(query)
 	var mainResponse []string
 	var followUp string
-	seenTypes := make(map[string]bool)
-
-	// Check for memory-related queries
-	if strings.Contains(query, "previous") || strings.Contains(query, "history") ||
-		strings.Contains(query, "locations") || strings.Contains(query, "remember") ||
-		strings.Contains(query, "which") && strings.Contains(query, "ask") {
-		return getMemoryInfo(), ""
-	}
-
-	// Handle follow-up questions about costs, attractions, etc.
-	if strings.Contains(query, "cost") || strings.Contains(query, "price") ||
-		strings.Contains(query, "attractions") || strings.Contains(query, "visit") ||
-		strings.Contains(query, "tax") {
-		if lastLocation != "" {
-			query = lastLocation + " " + query
+	session.QueryLocation = ""
+
+	if query == "" {
+		return noMatchResponse(corpus.Resolver, query), ""
+	}
+
+	// Make follow-ups standalone, so "and the hotels there?" searches for
+	// the hotels of the last location
+	ctx := context.Background()
+	search := rewriteQuery(ctx, session, corpus, query)
+
+	// First pass: find the location. A location named in the query wins,
+	// topic-only follow-ups stay on the last location and anything else
+	// goes through the retrieval pipeline.
+	analysis := AnalyzeQuery(search, corpus.Resolver)
+
+	matches := analysis.DistinctLocations(corpus.Resolver, session.LastLocation)
+	var locations []string
+	for _, match := range matches {
+		locations = append(locations, match.Location)
+	}
+
+	// Answer according to the kind of question; aggregates that cannot be
+	// executed are compared or looked up, and comparisons without several
+	// locations are looked up
+	intent := classifyQuery(ctx, session, IntentRequest{
+		Query:     query,
+		Search:    search,
+		Analysis:  analysis,
+		Locations: locations,
+	})
+	switch intent {
+	case IntentHistory:
+		return session.memoryInfo(), ""
+	case IntentSmalltalk:
+		return smalltalkResponse(query), ""
+	case IntentOutOfScope:
+		return outOfScopeResponse(corpus.Resolver), ""
+	case IntentAggregate:
+		// Superlative, filter and aggregate questions run over every
+		// record, or over the named locations when several are compared
+		if plan, ok := PlanQuery(search); ok {
+			var scope []Record
+			if len(locations) > 1 {
+				scope = storedRecords(corpus, locations...)
+			} else {
+				scope = storedRecords(corpus)
+			}
+			if answer, err := plan.Execute(scope); err == nil {
+				if len(locations) > 1 {
+					answer += "\n\n" + FormatComparison(locations, scope)
+					session.rememberLocations(locations, query)
+				}
+				return answer, ""
+			}
+		}
+		fallthrough
+	case IntentCompare:
+		// Comparisons get a side-by-side table of the named locations
+		if len(locations) > 1 {
+			session.rememberLocations(locations, query)
+			return FormatComparison(locations, storedRecords(corpus, locations...)), ""
+		}
+	case IntentLookup, IntentPlan:
+		// Questions naming no location that ask where to go, such as
+		// "somewhere warm in winter?", rank every location instead of
+		// looking one up
+		if len(locations) == 0 && recommendPattern.MatchString(strings.ToLower(search)) {
+			answer, recommended := recommendLocations(ctx, corpus, search)
+			if len(recommended) == 0 {
+				return noMatchResponse(corpus.Resolver, query), ""
+			}
+			session.rememberLocations(recommended, query)
+			return answer, ""
 		}
 	}
 
-	// Handle follow-up questions
-	if strings.HasPrefix(query, "what about") || strings.HasPrefix(query, "how about") {
-		query = strings.TrimPrefix(query, "what about")
-		query = strings.TrimPrefix(query, "how about")
-		query = strings.TrimSpace(query)
-	}
-
-	// If query is very short and we have a last location, assume it's about the last location
-	if len(strings.Fields(query)) <= 2 && lastLocation != "" &&
-		!strings.Contains(strings.ToLower(query), strings.ToLower(lastLocation)) {
-		query = lastLocation + " " + query
-	}
-
-	// Split query into words for better matching
-	queryWords := strings.Fields(query)
 	foundLocation := ""
-
-	// First pass: find the location
-	for _, record := range records {
-		location := strings.ToLower(record.Location)
-		for _, word := range queryWords {
-			if strings.Contains(location, word) {
-				foundLocation = record.Location
-				break
-			}
-		}
-		if foundLocation != "" {
-			break
+	if match, ok := analysis.BestLocation(corpus.Resolver, session.LastLocation); ok {
+		foundLocation = match.Location
+		if match.Fuzzy {
+			mainResponse = append(mainResponse, fmt.Sprintf("Showing results for %s (you asked about %q).",
+				foundLocation, match.Matched))
 		}
+	} else if analysis.IsFollowUp() {
+		foundLocation = session.LastLocation
+	} else {
+		foundLocation = locateQuery(ctx, corpus, analysis.SearchText(), session.LastLocation)
 	}
 
 	// Second pass: gather all information for the found location
 	if foundLocation != "" {
-		for _, record := range records {
-			if record.Location == foundLocation && !seenTypes[record.DataType] {
-				seenTypes[record.DataType] = true
-				info := formatRecordInfo(record)
-				mainResponse = append(mainResponse, info)
+		// Questions about a topic, such as "and the hotels?", only show
+		// the records of that topic
+		locationRecords, _ := corpus.Store.ByLocation(foundLocation)
+		merged, conflicts := mergeLocationRecords(locationRecords)
+		mainResponse = append(mainResponse, topicInfo(analysis, foundLocation, merged)...)
+		mainResponse = append(mainResponse, conflicts...)
+
+		// Quote the document passage that best answers the question
+		if passage, ok := documentPassage(corpus, search, foundLocation); ok {
+			mainResponse = append(mainResponse, formatRecordInfo(passage))
+		}
+
+		// Counties also surface their state's data and the combined tax
+		// rate; states list the tax rates of their counties when asked
+		// about taxes
+		if state, ok := corpus.Hierarchy.Parent(foundLocation); ok && len(mainResponse) > 0 {
+			stateRecords, _ := corpus.Store.ByLocation(state)
+			merged, conflicts := mergeLocationRecords(stateRecords)
+			mainResponse = append(mainResponse, topicInfo(analysis, state, merged)...)
+			mainResponse = append(mainResponse, conflicts...)
+			if analysis.asksAbout("tax") {
+				if combined, ok := CombinedTaxRate(storedRecords(corpus, foundLocation, state), corpus.Hierarchy, foundLocation); ok {
+					mainResponse = append(mainResponse, combined)
+				}
+			}
+		} else if counties := corpus.Hierarchy.Children(foundLocation); len(counties) > 0 && analysis.asksAbout("tax") {
+			if summary, ok := countyRatesSummary(storedRecords(corpus, counties...), corpus.Hierarchy, foundLocation); ok {
+				mainResponse = append(mainResponse, summary)
+			}
+		}
+
+		// Trip plans estimate the cost of the trip
+		if intent == IntentPlan && len(mainResponse) > 0 {
+			if estimate, ok := tripEstimate(corpus, foundLocation, search); ok {
+				mainResponse = append(mainResponse, estimate)
 			}
 		}
 
 		// If it's a new location
-		if foundLocation != lastLocation {
-			lastLocation = foundLocation
+		if foundLocation != session.LastLocation {
+			session.LastLocation = foundLocation
 			followUp = fmt.Sprintf("\nWould you like to know more about %s? You can ask about:\n"+
 				"- Tourist attractions and best time to visit\n"+
 				"- Average daily costs and expenses\n"+
@@ -240,138 +333,185 @@ func FindRelevantInfo(query string, records []Record) (string, string) {
 		}
 
 		// Add to interactions history
-		addInteraction(foundLocation, query)
+		session.addInteraction(foundLocation, query)
+		session.QueryLocation = foundLocation
 	}
 
 	if len(mainResponse) == 0 {
-		if lastLocation != "" {
-			return fmt.Sprintf("I assume you're asking about %s, but I don't have that specific information. Try asking about:\n"+
-				"- Tourist attractions and best time to visit\n"+
-				"- Average daily costs and expenses\n"+
-				"- Tax rates and financial information", lastLocation), ""
-		}
-		return fmt.Sprintf("No information found. Available locations: %s",
-			getAvailableLocations(records)), ""
+		return noMatchResponse(corpus.Resolver, query), ""
 	}
 
 	return strings.Join(mainResponse, "\n"), followUp
 }
 
-// addInteraction adds a new interaction to the memory
-func addInteraction(location, question string) {
-	interaction := Interaction{
-		Location:  location,
-		Question:  question,
-		Timestamp: time.Now(),
+// locateQuery runs the retrieval pipeline and returns the location of the
+// best record sharing a distinctive term with the query, so a place missing
+// from the data, such as "Orange County", is not answered with another
+// county that merely shares the word "county". The last location is passed
+// along so rerankers can keep ambiguous follow-ups on the current topic.
+func locateQuery(ctx context.Context, corpus *Corpus, query, lastLocation string) string {
+	terms := distinctiveTerms(query)
+	if len(terms) == 0 {
+		return ""
+	}
+	results, err := corpus.Pipeline.Retrieve(ctx, RetrievalRequest{Query: query, Location: lastLocation}, searchTopK)
+	if err != nil {
+		return ""
 	}
-
-	// Add to the beginning of the slice
-	interactions = append([]Interaction{interaction}, interactions...)
-
-	// Keep only the last memorySize interactions
-	if len(interactions) > memorySize {
-		interactions = interactions[:memorySize]
+	// Untagged document chunks have no location
+	for _, result := range results {
+		if result.Record.Location != "" && sharesTerm(result.Record, terms) {
+			return result.Record.Location
+		}
 	}
+	return ""
 }
 
-// getMemoryInfo returns a formatted string of recent interactions
-func getMemoryInfo() string {
-	if len(interactions) == 0 {
-		return "You haven't asked about any locations yet."
+// sharesTerm reports whether the record's text contains one of the terms
+func sharesTerm(record Record, terms map[string]bool) bool {
+	for _, term := range searchTerms(RecordText(record)) {
+		if terms[term] {
+			return true
+		}
 	}
+	return false
+}
 
-	var result strings.Builder
-	result.WriteString("Recent locations you've asked about:\n")
-
-	// Create a map to track unique locations
-	uniqueLocations := make(map[string]bool)
-
-	for _, interaction := range interactions {
-		uniqueLocations[interaction.Location] = true
+// storedRecords reads the records of the given locations from the corpus's
+// store, or every record when no location is given. Records the store
+// fails to read are left out, like those of a location without data.
+func storedRecords(corpus *Corpus, locations ...string) []Record {
+	if len(locations) == 0 {
+		records, _ := corpus.Store.Records()
+		return records
 	}
-
-	// List unique locations
-	result.WriteString("\nUnique locations discussed:\n")
-	for location := range uniqueLocations {
-		result.WriteString(fmt.Sprintf("- %s\n", location))
+	var records []Record
+	for _, location := range locations {
+		locationRecords, _ := corpus.Store.ByLocation(location)
+		records = append(records, locationRecords...)
 	}
+	return records
+}
 
-	// Show last interaction details
-	result.WriteString(fmt.Sprintf("\nMost recent query was about: %s\n", interactions[0].Location))
-
-	return result.String()
+// topicInfo formats the location's records on the topics the query asks
+// about, saying so when the location has data but none on those topics.
+// Documents are left to the passage search.
+func topicInfo(analysis AnalyzedQuery, location string, records []Record) []string {
+	var info []string
+	hasData := false
+	for _, record := range records {
+		if isDocument(record) {
+			continue
+		}
+		hasData = true
+		if analysis.asksAbout(record.DataType) {
+			info = append(info, formatRecordInfo(record))
+		}
+	}
+	if hasData && len(info) == 0 {
+		info = append(info, fmt.Sprintf("No %s data for %s.", strings.Join(analysis.Topics, " or "), location))
+	}
+	return info
 }
 
-// formatRecordInfo formats the record information based on its type
+// formatRecordInfo formats the record information based on its type.
+// Missing values are reported as not available instead of left blank.
 func formatRecordInfo(record Record) string {
+	if isDocument(record) {
+		return formatDocument(record)
+	}
+	return withFreshness(record, formatRecordValues(record))
+}
+
+// formatRecordValues describes a record's values by data type
+func formatRecordValues(record Record) string {
 	switch record.DataType {
 	case "tax":
 		return fmt.Sprintf("According to %s, the tax rate in %s is %s",
-			record.Source, record.Location, record.Values["tax_rate"])
+			record.Source, record.Location, recordValue(record, "tax_rate"))
+	case "county_tax":
+		return fmt.Sprintf("According to %s, the county tax rate in %s (%s) is %s",
+			record.Source, record.Location, recordValue(record, parentField), recordValue(record, "tax_rate"))
 	case "tourist":
 		return fmt.Sprintf("Tourist Information for %s (Source: %s):\n"+
 			"  - Main Attractions: %s\n"+
 			"  - Best Time to Visit: %s",
 			record.Location, record.Source,
-			record.Values["attractions"],
-			record.Values["best_time"])
+			recordValue(record, "attractions"),
+			recordValue(record, "best_time"))
 	case "cost":
 		return fmt.Sprintf("Travel Costs for %s (Source: %s):\n"+
-			"  - Average Daily Cost: $%s\n"+
-			"  - Hotel: $%s per night\n"+
-			"  - Food: $%s per day",
+			"  - Average Daily Cost: %s\n"+
+			"  - Hotel: %s\n"+
+			"  - Food: %s",
 			record.Location, record.Source,
-			record.Values["daily_cost"],
-			record.Values["hotel_avg"],
-			record.Values["food_avg"])
+			recordAmount(record, "daily_cost", ""),
+			recordAmount(record, "hotel_avg", " per night"),
+			recordAmount(record, "food_avg", " per day"))
 	default:
 		return fmt.Sprintf("Information about %s: %v (Source: %s)",
 			record.Location, record.Values, record.Source)
 	}
 }
 
-func getAvailableLocations(records []Record) string {
-	locations := make(map[string]bool)
-	for _, record := range records {
-		locations[record.Location] = true
+// notAvailable stands in for values missing from a record
+const notAvailable = "not available"
+
+// recordValue returns the raw value of a field
+func recordValue(record Record, field string) string {
+	if value := record.Values[field]; value != "" {
+		return value
+	}
+	return notAvailable
+}
+
+// recordAmount returns a currency field formatted as dollars, followed by
+// the suffix. Values that are not amounts, such as "n/a", are returned as
+// they are.
+func recordAmount(record Record, field, suffix string) string {
+	value := record.Values[field]
+	if value == "" {
+		return notAvailable
+	}
+	if _, ok := ParseNumeric(value); !ok {
+		return value
+	}
+	return currencyAmount(value) + suffix
+}
+
+// noMatchResponse explains that nothing matched and suggests the locations
+// closest to what was asked
+func noMatchResponse(resolver *LocationResolver, query string) string {
+	response := "No relevant information found in the database."
+	if suggestions := resolver.Suggest(query, 3); len(suggestions) > 0 {
+		return response + fmt.Sprintf(" Did you mean %s?", joinOr(suggestions))
+	}
+	if locations := resolver.Locations(); len(locations) > 0 {
+		return response + fmt.Sprintf(" Try asking about a location, e.g. 'Tell me about %s'.", locations[0])
 	}
+	return response
+}
 
-	var uniqueLocations []string
-	for location := range locations {
-		uniqueLocations = append(uniqueLocations, location)
+// joinOr joins items as "a, b or c"
+func joinOr(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
 	}
-	return strings.Join(uniqueLocations, ", ")
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
 }
 
-// GenerateAnswer uses the OpenAI API to generate an answer
-func GenerateAnswer(client *openai.Client, mainInfo, followUp, question string) (string, error) {
-	if client == nil {
+// GenerateAnswer uses the session's LLM provider to generate an answer,
+// sending the recent turns of the conversation along with the question
+func GenerateAnswer(session *Session, mainInfo, followUp, question string) (string, error) {
+	provider := session.Provider
+	if provider == nil {
 		if followUp != "" {
 			return mainInfo + followUp, nil
 		}
 		return mainInfo, nil
 	}
 
-	var prompt string
-	if lastQuery != "" {
-		prompt = fmt.Sprintf("Previous question: %s\nCurrent question: %s\nInformation: %s",
-			lastQuery, question, mainInfo)
-	} else {
-		prompt = fmt.Sprintf("Question: %s\nInformation: %s", question, mainInfo)
-	}
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT3Dot5Turbo,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-		},
-	)
+	answer, err := provider.ChatCompletion(context.Background(), session.Messages(question, mainInfo))
 
 	if err != nil {
 		if followUp != "" {
@@ -380,36 +520,206 @@ func GenerateAnswer(client *openai.Client, mainInfo, followUp, question string)
 		return mainInfo, nil
 	}
 
-	answer := resp.Choices[0].Message.Content
 	if followUp != "" {
 		answer += followUp
 	}
 	return answer, nil
 }
 
+// loadCorpus loads the configured sources into a corpus ready for queries,
+// keeping its records in store when one is given. A store already holding
+// the current sources is queried as it is. With strict set, a source that
+// fails to load fails the whole load instead of being skipped. Warnings
+// are passed to warn.
+func loadCorpus(ctx context.Context, embedder Embedder, provider LLMProvider, store RecordStore, strict bool, warn func(string)) (*Corpus, error) {
+	sources, err := configuredSources()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := LoadAliases(aliasesPath)
+	if err != nil {
+		warn(fmt.Sprintf("Error loading %s: %v", aliasesPath, err))
+	}
+
+	// Unreadable sources leave the version empty and are reported below
+	version, _ := SourcesVersion(sources, embedder, aliases)
+	if store != nil && version != "" {
+		if stored, err := store.Version(); err == nil && stored == version {
+			if corpus, err := OpenStoredCorpus(store, embedder, aliases); err == nil {
+				corpus.Version = version
+				configurePipeline(corpus, provider, warn)
+				return corpus, nil
+			}
+		}
+	}
+
+	// Load records from the vector index, re-embedding only changed data files
+	corpus, update, err := OpenIndexedCorpus(ctx, indexPath, sources, embedder, aliases)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range slices.Sorted(maps.Keys(update.Failed)) {
+		if strict {
+			return nil, fmt.Errorf("error loading %s: %v", file, update.Failed[file])
+		}
+		warn(fmt.Sprintf("Error loading %s: %v", file, update.Failed[file]))
+	}
+	if len(update.Failed) == 0 {
+		corpus.Version = version
+	}
+
+	if len(corpus.Records) == 0 {
+		return nil, fmt.Errorf("no records loaded")
+	}
+
+	// Persistent stores are only rewritten when the sources changed
+	if store != nil {
+		if err := store.Replace(corpus.Records, corpus.Vectors.chunks, corpus.Version); err != nil {
+			if strict {
+				return nil, err
+			}
+			warn(fmt.Sprintf("%v, keeping records in memory", err))
+		} else {
+			corpus.Store = store
+		}
+	}
+
+	configurePipeline(corpus, provider, warn)
+	return corpus, nil
+}
+
+// configurePipeline sets up the retrieval pipeline for this deployment
+// over the corpus's store and vectors, passing warnings to warn
+func configurePipeline(corpus *Corpus, provider LLMProvider, warn func(string)) {
+	pipeline, err := NewRetrievalPipeline(corpus, retrievalConfig, provider)
+	if err != nil {
+		warn(fmt.Sprintf("%v, using the default retrieval pipeline", err))
+	} else {
+		pipeline.Log = os.Stdout
+		corpus.Pipeline = pipeline
+	}
+}
+
 func runQuery(cmd *cobra.Command, args []string) {
-	// Load records from all data files
-	var allRecords []Record
-	for dataType, file := range dataFiles {
-		records, err := LoadData(file, dataType)
+	embedder, err := NewEmbedder(embedderName)
+	if err != nil {
+		fmt.Printf("Warning: %v, using the %s embedder\n", err, EmbedderHashing)
+		embedder = NewHashingEmbedder(defaultHashingDimensions)
+	}
+
+	if err := mergeConfig.Validate(); err != nil {
+		fmt.Printf("Warning: %v, using the %s merge policy\n", err, MergeNewest)
+		mergeConfig = DefaultMergeConfig()
+	}
+
+	// Initialize the LLM provider from flags, falling back to the environment
+	provider, err := NewProvider(providerConfig)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	rewriter, err := NewQueryRewriter(rewriterName, provider)
+	if err != nil {
+		fmt.Printf("Warning: %v, using the %s rewriter\n", err, RewriterHeuristic)
+		rewriter = HeuristicRewriter{}
+	}
+	classifier, err := NewIntentClassifier(classifierName, provider)
+	if err != nil {
+		fmt.Printf("Warning: %v, using the %s classifier\n", err, ClassifierRules)
+		classifier = RuleClassifier{}
+	}
+
+	// Resume a named session, then prune the others past their retention
+	session := NewSession(provider)
+	var sessions *SessionStore
+	if sessionName != "" {
+		sessions = configuredSessionStore()
+		session, err = sessions.Open(sessionName, provider)
 		if err != nil {
-			fmt.Printf("Warning: Error loading %s: %v\n", file, err)
-			continue
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(session.Turns) > 0 {
+			fmt.Printf("Resuming session %s (%s)\n", session.Name, count(len(session.Turns), "previous question"))
+		}
+		if _, err := sessions.Prune(time.Now(), session.Name); err != nil {
+			fmt.Printf("Warning: %v\n", err)
 		}
-		allRecords = append(allRecords, records...)
+	}
+	session.HistoryTokens = historyTokens
+	session.Rewriter = rewriter
+	session.Classifier = classifier
+	if debugOutput {
+		session.Log = os.Stderr
 	}
 
-	if len(allRecords) == 0 {
-		fmt.Println("Error: No records loaded")
+	var store RecordStore
+	if storeName != StoreMemory {
+		store, err = OpenStore(storeName, storePath)
+		if err != nil {
+			fmt.Printf("Warning: %v, keeping records in memory\n", err)
+			store = nil
+		} else {
+			defer store.Close()
+		}
+	}
+
+	// Snapshot the data files before loading so no edit goes unnoticed
+	var watcher *SourceWatcher
+	if watchData {
+		watcher, err = NewSourceWatcher(func() ([]string, error) {
+			return WatchedPaths(dataPaths, manifestPath)
+		})
+		if err != nil {
+			fmt.Printf("Warning: %v, not watching data files\n", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	corpus, err := loadCorpus(ctx, embedder, provider, store, false, func(warning string) {
+		fmt.Printf("Warning: %s\n", warning)
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	// Initialize OpenAI client if API key is available
-	var client *openai.Client
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-		client = openai.NewClient(apiKey)
+	// Reload notices and warnings interrupt the prompt, so they are
+	// printed on their own lines before showing it again
+	notify := func(notice string) {
+		fmt.Printf("\n[%s]\n> ", notice)
+	}
+	reloadWarn := func(warning string) {
+		notify("Warning: " + warning)
+	}
+
+	// Reloads load into memory while queries go on; the persistent store
+	// queries read is only rewritten once the load succeeded
+	reloader := NewCorpusReloader(corpus, func(ctx context.Context) (*Corpus, error) {
+		return loadCorpus(ctx, embedder, provider, nil, true, reloadWarn)
+	})
+	if store != nil && corpus.Store == store {
+		reloader.Install = func(corpus *Corpus) error {
+			if err := store.Replace(corpus.Records, corpus.Vectors.chunks, corpus.Version); err != nil {
+				return err
+			}
+			corpus.Store = store
+			configurePipeline(corpus, provider, reloadWarn)
+			return nil
+		}
+	}
+
+	if provider == nil {
+		fmt.Println("\nNote: No LLM provider configured. Running in basic mode without AI-enhanced responses.")
 	} else {
-		fmt.Println("\nNote: OPENAI_API_KEY not set. Running in basic mode without AI-enhanced responses.")
+		fmt.Printf("\nUsing model %s\n", provider.ModelName())
+	}
+
+	// Reload the data files when they change, keeping the previous data
+	// when the new files do not load
+	if watcher != nil {
+		go reloader.Watch(ctx, watcher, watchInterval, notify)
 	}
 
 	fmt.Println("\nWelcome to the Travel Information System!")
@@ -433,18 +743,26 @@ func runQuery(cmd *cobra.Command, args []string) {
 			continue
 		}
 
-		// Find relevant information
-		mainInfo, followUp := FindRelevantInfo(question, allRecords)
+		// Find relevant information in the current data
+		var mainInfo, followUp string
+		reloader.Use(func(corpus *Corpus) {
+			mainInfo, followUp = FindRelevantInfo(session, corpus, question)
+		})
 
 		// Generate answer
-		answer, err := GenerateAnswer(client, mainInfo, followUp, question)
+		answer, err := GenerateAnswer(session, mainInfo, followUp, question)
 		if err != nil {
 			fmt.Printf("Warning: %v\n", err)
 		}
 
 		fmt.Printf("\n%s\n", answer)
 
-		// Store the current question for context
-		lastQuery = question
+		// Keep the question and answer for context, saving named sessions
+		turn := session.RecordTurn(question, session.QueryLocation, mainInfo, answer)
+		if sessions != nil {
+			if err := sessions.Append(session.Name, turn); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
 	}
 }
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxRecommendations is the number of locations a recommendation lists
+const maxRecommendations = 3
+
+// recommendPattern matches questions asking where to go rather than about
+// a named place, such as "somewhere warm in winter"
+var recommendPattern = regexp.MustCompile(`\b(?:somewhere|anywhere|recommend|suggest|where\s+(?:should|could|can)\s+(?:i|we)\s+(?:go|travel|visit))\b`)
+
+// seasonMonths are the months of each season, in the northern hemisphere
+var seasonMonths = map[string][]time.Month{
+	"winter": {time.December, time.January, time.February},
+	"spring": {time.March, time.April, time.May},
+	"summer": {time.June, time.July, time.August},
+	"fall":   {time.September, time.October, time.November},
+	"autumn": {time.September, time.October, time.November},
+}
+
+// monthRangePattern matches ranges of months such as "November to April"
+var monthRangePattern = regexp.MustCompile(`(?i)\b([a-z]+)\s+(?:to|through|-)\s+([a-z]+)\b`)
+
+// parseMonth parses a month name or its three letter abbreviation
+func parseMonth(word string) (time.Month, bool) {
+	word = strings.ToLower(word)
+	if len(word) < 3 {
+		return 0, false
+	}
+	for month := time.January; month <= time.December; month++ {
+		name := strings.ToLower(month.String())
+		if word == name || word == name[:3] {
+			return month, true
+		}
+	}
+	return 0, false
+}
+
+// queryMonths returns the months named in the query, directly or by season
+func queryMonths(query string) []time.Month {
+	var months []time.Month
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, "?.!,")
+		if season, ok := seasonMonths[word]; ok {
+			months = append(months, season...)
+		} else if month, ok := parseMonth(word); ok && len(word) > 3 {
+			months = append(months, month)
+		}
+	}
+	return months
+}
+
+// monthsCovered counts the months that fall in a range such as
+// "November to April", which wraps around the end of the year
+func monthsCovered(value string, months []time.Month) int {
+	match := monthRangePattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0
+	}
+	from, ok := parseMonth(match[1])
+	if !ok {
+		return 0
+	}
+	to, ok := parseMonth(match[2])
+	if !ok {
+		return 0
+	}
+
+	covered := 0
+	for _, month := range months {
+		if from <= to && month >= from && month <= to ||
+			from > to && (month >= from || month <= to) {
+			covered++
+		}
+	}
+	return covered
+}
+
+// recommendLocations answers questions asking where to go by ranking every
+// location: by how much of the season or months asked about falls in its
+// best time to visit, or else by retrieval over the whole corpus. It
+// returns the answer and the locations it lists, best first.
+func recommendLocations(ctx context.Context, corpus *Corpus, query string) (string, []string) {
+	var ranked []Record
+	if months := queryMonths(query); len(months) > 0 {
+		type candidate struct {
+			record  Record
+			covered int
+		}
+		var candidates []candidate
+		for _, record := range mergedRecords(storedRecords(corpus)) {
+			if covered := monthsCovered(record.Values["best_time"], months); covered > 0 {
+				candidates = append(candidates, candidate{record, covered})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].covered > candidates[j].covered
+		})
+		for _, c := range candidates {
+			ranked = append(ranked, c.record)
+		}
+	} else if terms := distinctiveTerms(query); len(terms) > 0 {
+		results, err := corpus.Pipeline.Retrieve(ctx, RetrievalRequest{Query: query}, searchTopK)
+		if err != nil {
+			return "", nil
+		}
+		for _, result := range results {
+			if result.Record.Location != "" && sharesTerm(result.Record, terms) {
+				ranked = append(ranked, result.Record)
+			}
+		}
+	}
+
+	var locations, parts []string
+	seen := make(map[string]bool)
+	for _, record := range ranked {
+		if seen[record.Location] || len(locations) == maxRecommendations {
+			continue
+		}
+		seen[record.Location] = true
+		locations = append(locations, record.Location)
+		parts = append(parts, formatRecordInfo(record))
+	}
+	if len(locations) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("Locations matching your question, best first: %s\n\n%s",
+		strings.Join(locations, ", "), strings.Join(parts, "\n")), locations
+}
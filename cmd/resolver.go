@@ -0,0 +1,416 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fuzzy matching thresholds
+const (
+	minFuzzyLength     = 5   // shorter words must match exactly
+	minResolveTrigram  = 0.7 // trigram similarity accepted as a match
+	minSuggestTrigram  = 0.3 // trigram similarity worth suggesting
+	maxSuggestDistance = 3   // edit distance worth suggesting
+	maxLocationWords   = 4   // longest location name or alias in words
+
+	fuzzyScoreFactor   = 0.9 // fuzzy matches rank below exact ones
+	partialScoreFactor = 0.8 // partial names rank below full ones
+)
+
+// LocationMatch is a location recognised in a query
+type LocationMatch struct {
+	Location string  // canonical location name
+	Matched  string  // query text that matched
+	Alias    string  // alias used, empty when the name itself matched
+	Score    float64 // 1 for exact matches, lower for fuzzy ones
+	Fuzzy    bool
+	Partial  bool // only some words of a multi-word name matched
+	Position int  // word offset of the match in the query
+}
+
+// LocationResolver maps location names, aliases and misspellings found in a
+// query to canonical locations
+type LocationResolver struct {
+	locations []string
+	order     map[string]int      // position of each location in the input
+	names     map[string]string   // normalised name or alias -> location
+	aliases   map[string]bool     // normalised names that are aliases
+	words     map[string][]string // distinctive name word -> locations
+}
+
+// NewLocationResolver creates a resolver for the locations. Aliases map an
+// alias to its location; aliases of unknown locations are ignored.
+func NewLocationResolver(locations []string, aliases map[string]string) *LocationResolver {
+	r := &LocationResolver{
+		order:   make(map[string]int),
+		names:   make(map[string]string),
+		aliases: make(map[string]bool),
+		words:   make(map[string][]string),
+	}
+
+	canonical := make(map[string]string)
+	for _, location := range locations {
+		key := normalizeName(location)
+		if key == "" {
+			continue
+		}
+		if _, ok := canonical[key]; ok {
+			continue
+		}
+		r.order[location] = len(r.locations)
+		r.locations = append(r.locations, location)
+		canonical[key] = location
+		r.names[key] = location
+
+		// Words of multi-word names allow partial matches such as "Travis"
+		words := strings.Fields(key)
+		if len(words) < 2 {
+			continue
+		}
+		for _, word := range words {
+			if !genericLocationWords[word] && !stopWords[word] {
+				r.words[word] = append(r.words[word], location)
+			}
+		}
+	}
+
+	for alias, location := range aliases {
+		target, ok := canonical[normalizeName(location)]
+		key := normalizeName(alias)
+		if !ok || key == "" {
+			continue
+		}
+		if _, exists := r.names[key]; exists {
+			continue
+		}
+		r.names[key] = target
+		r.aliases[key] = true
+	}
+
+	return r
+}
+
+// Locations returns the known canonical locations in alphabetical order
+func (r *LocationResolver) Locations() []string {
+	locations := append([]string(nil), r.locations...)
+	sort.Strings(locations)
+	return locations
+}
+
+// normalizeName lowercases a name and collapses punctuation and spacing
+func normalizeName(name string) string {
+	return strings.Join(nameWords(name), " ")
+}
+
+// nameWords splits text into lowercase words without dropping stop words,
+// since aliases such as "NY" or names such as "New York" must stay intact
+func nameWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Resolve returns the locations mentioned in the query in the order they
+// appear. Exact names and aliases are matched before misspellings, then
+// single distinctive words of multi-word names; longer phrases win over
+// the words inside them. An ambiguous partial word such as "Travis" yields
+// one match per location sharing it.
+func (r *LocationResolver) Resolve(query string) []LocationMatch {
+	words := nameWords(query)
+	used := make([]bool, len(words))
+	var matches []LocationMatch
+
+	for _, fuzzy := range []bool{false, true} {
+		for size := maxLocationWords; size >= 1; size-- {
+			for start := 0; start+size <= len(words); start++ {
+				if anyUsed(used[start : start+size]) {
+					continue
+				}
+				phrase := strings.Join(words[start:start+size], " ")
+				match, ok := r.match(phrase, size, fuzzy)
+				if !ok {
+					continue
+				}
+				match.Position = start
+				matches = append(matches, match)
+				for i := start; i < start+size; i++ {
+					used[i] = true
+				}
+			}
+		}
+	}
+
+	for i, word := range words {
+		if used[i] {
+			continue
+		}
+		for _, location := range r.words[word] {
+			share := 1 / float64(len(strings.Fields(normalizeName(location))))
+			matches = append(matches, LocationMatch{
+				Location: location,
+				Matched:  word,
+				Score:    partialScoreFactor * share,
+				Partial:  true,
+				Position: i,
+			})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Position < matches[j].Position
+	})
+	return dedupeMatches(matches)
+}
+
+// match checks a query phrase of size words against every name and
+// alias, either exactly or tolerating typos
+func (r *LocationResolver) match(phrase string, size int, fuzzy bool) (LocationMatch, bool) {
+	if stopWords[phrase] {
+		return LocationMatch{}, false
+	}
+	if !fuzzy {
+		location, ok := r.names[phrase]
+		if !ok {
+			return LocationMatch{}, false
+		}
+		match := LocationMatch{Location: location, Matched: phrase, Score: 1}
+		if r.aliases[phrase] {
+			match.Alias = phrase
+		}
+		return match, true
+	}
+
+	if len([]rune(phrase)) < minFuzzyLength {
+		return LocationMatch{}, false
+	}
+
+	var best LocationMatch
+	for name, location := range r.names {
+		// Only compare phrases with names of the same number of words
+		if len([]rune(name)) < minFuzzyLength || strings.Count(name, " ")+1 != size {
+			continue
+		}
+		got, want, ok := distinctiveParts(phrase, name)
+		if !ok {
+			continue
+		}
+		distance := editDistance(got, want)
+		similarity := trigramSimilarity(got, want)
+		if distance > maxEdits(want) && similarity < minResolveTrigram {
+			continue
+		}
+
+		score := 1 - float64(distance)/float64(max(len([]rune(got)), len([]rune(want))))
+		score = fuzzyScoreFactor * max(score, similarity)
+		if score > best.Score || (score == best.Score && location < best.Location) {
+			best = LocationMatch{Location: location, Matched: phrase, Score: score, Fuzzy: true}
+			if r.aliases[name] {
+				best.Alias = name
+			}
+		}
+	}
+	return best, best.Location != ""
+}
+
+// distinctiveParts pairs the words of a phrase with those of a name of the
+// same length and returns the non-generic words of both. Generic words
+// such as "state" must match exactly, so "golden gate" is not a typo of
+// "golden state"; typos are only tolerated in the distinctive part.
+func distinctiveParts(phrase, name string) (string, string, bool) {
+	phraseWords, nameWords := strings.Fields(phrase), strings.Fields(name)
+	if len(phraseWords) != len(nameWords) {
+		return "", "", false
+	}
+
+	var got, want []string
+	for i, word := range nameWords {
+		if genericLocationWords[word] || genericLocationWords[phraseWords[i]] {
+			if word != phraseWords[i] {
+				return "", "", false
+			}
+			continue
+		}
+		got = append(got, phraseWords[i])
+		want = append(want, word)
+	}
+	return strings.Join(got, " "), strings.Join(want, " "), len(want) > 0
+}
+
+// Suggest returns up to n locations whose names or aliases look like words
+// in the query, most similar first
+func (r *LocationResolver) Suggest(query string, n int) []string {
+	words := nameWords(query)
+	scores := make(map[string]float64)
+
+	for size := 1; size <= maxLocationWords; size++ {
+		for start := 0; start+size <= len(words); start++ {
+			phrase := strings.Join(words[start:start+size], " ")
+			if len([]rune(phrase)) < 3 || stopWords[phrase] {
+				continue
+			}
+			for name, location := range r.names {
+				similarity := trigramSimilarity(phrase, name)
+				if editDistance(phrase, name) > maxSuggestDistance && similarity < minSuggestTrigram {
+					continue
+				}
+				if similarity > scores[location] {
+					scores[location] = similarity
+				}
+			}
+		}
+	}
+
+	suggestions := make([]string, 0, len(scores))
+	for location := range scores {
+		suggestions = append(suggestions, location)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if scores[suggestions[i]] != scores[suggestions[j]] {
+			return scores[suggestions[i]] > scores[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if n > 0 && len(suggestions) > n {
+		suggestions = suggestions[:n]
+	}
+	return suggestions
+}
+
+// maxEdits is the edit distance tolerated for a name of this length
+func maxEdits(name string) int {
+	if len([]rune(name)) >= 8 {
+		return 2
+	}
+	return 1
+}
+
+func anyUsed(used []bool) bool {
+	for _, u := range used {
+		if u {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupeMatches keeps the first match of every location
+func dedupeMatches(matches []LocationMatch) []LocationMatch {
+	seen := make(map[string]bool)
+	var result []LocationMatch
+	for _, match := range matches {
+		if seen[match.Location] {
+			continue
+		}
+		seen[match.Location] = true
+		result = append(result, match)
+	}
+	return result
+}
+
+// editDistance is the optimal string alignment distance, counting an
+// adjacent transposition such as "ro" -> "or" as a single edit
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, min(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// trigramSimilarity is the Dice coefficient of the padded character trigrams
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	var shared int
+	for gram := range ta {
+		if tb[gram] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ta)+len(tb))
+}
+
+func trigrams(s string) map[string]bool {
+	runes := []rune("  " + s + " ")
+	grams := make(map[string]bool)
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = true
+	}
+	return grams
+}
+
+// LoadAliases reads an alias file with alias and location columns.
+// A missing file yields no aliases.
+func LoadAliases(path string) (map[string]string, error) {
+	if err := validateFilePath(path); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %v", err)
+	}
+
+	aliases := make(map[string]string)
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && strings.EqualFold(row[0], "alias") {
+			continue
+		}
+		if len(row) < 2 {
+			return nil, fmt.Errorf("invalid alias at row %d: expected alias and location", i+1)
+		}
+		if err := validateRecord(row); err != nil {
+			return nil, fmt.Errorf("invalid alias at row %d: %v", i+1, err)
+		}
+		aliases[strings.TrimSpace(row[0])] = strings.TrimSpace(row[1])
+	}
+	return aliases, nil
+}
+
+// recordLocations returns the distinct locations of the records
+func recordLocations(records []Record) []string {
+	seen := make(map[string]bool)
+	var locations []string
+	for _, record := range records {
+		if record.Location == "" || seen[record.Location] {
+			continue
+		}
+		seen[record.Location] = true
+		locations = append(locations, record.Location)
+	}
+	return locations
+}
@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Retriever and reranker names accepted in RetrievalConfig
+const (
+	RetrieverBM25   = "bm25"
+	RetrieverVector = "vector"
+
+	RerankerNone     = "none"
+	RerankerFeatures = "features"
+	RerankerLLM      = "llm"
+
+	defaultFusionK    = 60
+	defaultRerankTopN = 20
+)
+
+// RetrievalRequest is a query plus the conversational context it was asked in
+type RetrievalRequest struct {
+	Query    string
+	Location string // location currently under discussion, if any
+}
+
+// Retriever returns ranked candidate records for a query
+type Retriever interface {
+	Name() string
+	Retrieve(ctx context.Context, query string, k int) ([]ScoredRecord, error)
+}
+
+// Reranker reorders the fused candidates, best first
+type Reranker interface {
+	Name() string
+	Rerank(ctx context.Context, req RetrievalRequest, candidates []ScoredRecord) ([]ScoredRecord, error)
+}
+
+// RetrievalConfig tunes the pipeline per deployment
+type RetrievalConfig struct {
+	Retrievers []string
+	Reranker   string
+	RerankTopN int
+	FusionK    int
+}
+
+// DefaultRetrievalConfig fuses lexical and vector search and reranks locally
+func DefaultRetrievalConfig() RetrievalConfig {
+	return RetrievalConfig{
+		Retrievers: []string{RetrieverBM25, RetrieverVector},
+		Reranker:   RerankerFeatures,
+		RerankTopN: defaultRerankTopN,
+		FusionK:    defaultFusionK,
+	}
+}
+
+// VectorRetriever adapts a VectorIndex to the Retriever interface
+type VectorRetriever struct {
+	Index *VectorIndex
+}
+
+// Name identifies the retriever
+func (r VectorRetriever) Name() string {
+	return RetrieverVector
+}
+
+// Retrieve runs cosine similarity search
+func (r VectorRetriever) Retrieve(ctx context.Context, query string, k int) ([]ScoredRecord, error) {
+	return r.Index.Search(ctx, query, k)
+}
+
+// LexicalRetriever adapts the full-text search of a RecordStore to the
+// Retriever interface
+type LexicalRetriever struct {
+	Store RecordStore
+}
+
+// Name identifies the retriever
+func (r LexicalRetriever) Name() string {
+	return RetrieverBM25
+}
+
+// Retrieve runs BM25 search
+func (r LexicalRetriever) Retrieve(ctx context.Context, query string, k int) ([]ScoredRecord, error) {
+	return r.Store.Search(query, k)
+}
+
+// RetrievalPipeline runs several retrievers, fuses their rankings with
+// reciprocal rank fusion and optionally reranks the top candidates
+type RetrievalPipeline struct {
+	Retrievers []Retriever
+	FusionK    int
+	Reranker   Reranker
+	RerankTopN int
+	// Log receives reranker failures, which leave the fused order in
+	// place; nil discards them
+	Log io.Writer
+}
+
+// NewRetrievalPipeline builds the pipeline described by cfg over the corpus.
+// The provider is only needed by the LLM reranker.
+func NewRetrievalPipeline(corpus *Corpus, cfg RetrievalConfig, provider LLMProvider) (*RetrievalPipeline, error) {
+	pipeline := &RetrievalPipeline{FusionK: cfg.FusionK, RerankTopN: cfg.RerankTopN}
+	if pipeline.FusionK <= 0 {
+		pipeline.FusionK = defaultFusionK
+	}
+	if pipeline.RerankTopN <= 0 {
+		pipeline.RerankTopN = defaultRerankTopN
+	}
+
+	for _, name := range cfg.Retrievers {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case RetrieverBM25:
+			pipeline.Retrievers = append(pipeline.Retrievers, LexicalRetriever{Store: corpus.Store})
+		case RetrieverVector:
+			pipeline.Retrievers = append(pipeline.Retrievers, VectorRetriever{Index: corpus.Vectors})
+		default:
+			return nil, fmt.Errorf("unknown retriever %q", name)
+		}
+	}
+	if len(pipeline.Retrievers) == 0 {
+		return nil, fmt.Errorf("at least one retriever is required")
+	}
+
+	switch strings.ToLower(cfg.Reranker) {
+	case "", RerankerNone:
+	case RerankerFeatures:
+		pipeline.Reranker = NewFeatureReranker()
+	case RerankerLLM:
+		if provider == nil {
+			return nil, fmt.Errorf("the %s reranker requires an LLM provider", RerankerLLM)
+		}
+		pipeline.Reranker = &LLMReranker{Provider: provider}
+	default:
+		return nil, fmt.Errorf("unknown reranker %q", cfg.Reranker)
+	}
+
+	return pipeline, nil
+}
+
+// Retrieve returns up to k records for the request, best first
+func (p *RetrievalPipeline) Retrieve(ctx context.Context, req RetrievalRequest, k int) ([]ScoredRecord, error) {
+	candidateK := p.RerankTopN
+	if k > candidateK {
+		candidateK = k
+	}
+
+	var rankings [][]ScoredRecord
+	for _, retriever := range p.Retrievers {
+		results, err := retriever.Retrieve(ctx, req.Query, candidateK)
+		if err != nil {
+			return nil, fmt.Errorf("%s retriever: %v", retriever.Name(), err)
+		}
+		rankings = append(rankings, results)
+	}
+
+	fused := ReciprocalRankFusion(p.FusionK, rankings...)
+
+	if p.Reranker != nil && len(fused) > 0 {
+		top := fused
+		if len(top) > p.RerankTopN {
+			top = top[:p.RerankTopN]
+		}
+		reranked, err := p.Reranker.Rerank(ctx, req, top)
+		if err != nil {
+			if p.Log != nil {
+				fmt.Fprintf(p.Log, "Warning: %s reranker failed, keeping the fused order: %v\n", p.Reranker.Name(), err)
+			}
+		} else {
+			fused = append(reranked, fused[len(top):]...)
+		}
+	}
+
+	if k > 0 && len(fused) > k {
+		fused = fused[:k]
+	}
+	return fused, nil
+}
+
+// ReciprocalRankFusion merges rankings by summing 1/(k+rank) per record.
+// Records are identified by their Index in the shared record set.
+func ReciprocalRankFusion(k int, rankings ...[]ScoredRecord) []ScoredRecord {
+	scores := make(map[int]float64)
+	records := make(map[int]Record)
+	for _, ranking := range rankings {
+		for rank, result := range ranking {
+			scores[result.Index] += 1 / float64(k+rank+1)
+			records[result.Index] = result.Record
+		}
+	}
+
+	fused := make([]ScoredRecord, 0, len(scores))
+	for i, score := range scores {
+		fused = append(fused, ScoredRecord{Record: records[i], Index: i, Score: score})
+	}
+	sortScored(fused)
+	return fused
+}
+
+// sortScored orders results by score, breaking ties by record order
+func sortScored(results []ScoredRecord) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Index < results[j].Index
+	})
+}
+
+// FeatureWeights weight the signals combined by the FeatureReranker
+type FeatureWeights struct {
+	Retrieval float64 // fused retrieval score relative to the best candidate
+	Location  float64 // share of the location name mentioned in the query
+	Coverage  float64 // share of query terms found in the record
+	Context   float64 // record is about the location under discussion
+}
+
+// FeatureReranker is a local cross-feature scorer looking at the query and
+// each candidate together
+type FeatureReranker struct {
+	Weights FeatureWeights
+}
+
+// NewFeatureReranker creates a feature reranker with default weights
+func NewFeatureReranker() *FeatureReranker {
+	return &FeatureReranker{Weights: FeatureWeights{
+		Retrieval: 1.0,
+		Location:  1.0,
+		Coverage:  0.5,
+		Context:   0.2,
+	}}
+}
+
+// Name identifies the reranker
+func (r *FeatureReranker) Name() string {
+	return RerankerFeatures
+}
+
+// Rerank scores every candidate on its features and sorts by the result
+func (r *FeatureReranker) Rerank(ctx context.Context, req RetrievalRequest, candidates []ScoredRecord) ([]ScoredRecord, error) {
+	queryTerms := tokenize(req.Query)
+	querySet := make(map[string]bool)
+	for _, term := range queryTerms {
+		querySet[term] = true
+	}
+
+	var maxScore float64
+	for _, candidate := range candidates {
+		if candidate.Score > maxScore {
+			maxScore = candidate.Score
+		}
+	}
+
+	reranked := make([]ScoredRecord, len(candidates))
+	for i, candidate := range candidates {
+		var retrieval float64
+		if maxScore > 0 {
+			retrieval = candidate.Score / maxScore
+		}
+
+		score := r.Weights.Retrieval*retrieval +
+			r.Weights.Location*termShare(tokenize(candidate.Record.Location), querySet) +
+			r.Weights.Coverage*termShare(queryTerms, tokenSet(RecordText(candidate.Record)))
+		if req.Location != "" && candidate.Record.Location == req.Location {
+			score += r.Weights.Context
+		}
+
+		reranked[i] = candidate
+		reranked[i].Score = score
+	}
+
+	sortScored(reranked)
+	return reranked, nil
+}
+
+// tokenSet returns the distinct tokens of a text
+func tokenSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, token := range tokenize(text) {
+		set[token] = true
+	}
+	return set
+}
+
+// termShare returns the fraction of terms present in the set
+func termShare(terms []string, set map[string]bool) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+	var found int
+	for _, term := range terms {
+		if set[term] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(terms))
+}
+
+// LLMReranker asks the LLM to order the candidates by relevance
+type LLMReranker struct {
+	Provider LLMProvider
+}
+
+// Name identifies the reranker
+func (r *LLMReranker) Name() string {
+	return RerankerLLM
+}
+
+var rankNumberPattern = regexp.MustCompile(`\d+`)
+
+// Rerank sends the numbered candidates to the LLM and applies the order it
+// returns. Candidates the LLM does not mention keep their relative order.
+func (r *LLMReranker) Rerank(ctx context.Context, req RetrievalRequest, candidates []ScoredRecord) ([]ScoredRecord, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Question: %s\n", req.Query)
+	if req.Location != "" {
+		fmt.Fprintf(&prompt, "The conversation is currently about: %s\n", req.Location)
+	}
+	prompt.WriteString("\nRecords:\n")
+	for i, candidate := range candidates {
+		fmt.Fprintf(&prompt, "[%d] %s\n", i+1, strings.ReplaceAll(RecordText(candidate.Record), "\n", "; "))
+	}
+	prompt.WriteString("\nReply only with the record numbers ordered from most to least relevant, comma separated.")
+
+	reply, err := r.Provider.ChatCompletion(ctx, []ChatMessage{
+		{Role: RoleSystem, Content: "You rank search results for a travel and tax information assistant."},
+		{Role: RoleUser, Content: prompt.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[int]bool)
+	var reranked []ScoredRecord
+	for _, match := range rankNumberPattern.FindAllString(reply, -1) {
+		n, err := strconv.Atoi(match)
+		if err != nil || n < 1 || n > len(candidates) || used[n-1] {
+			continue
+		}
+		used[n-1] = true
+		reranked = append(reranked, candidates[n-1])
+	}
+	for i, candidate := range candidates {
+		if !used[i] {
+			reranked = append(reranked, candidate)
+		}
+	}
+
+	// Scores follow the new order so later stages can rely on them
+	for i := range reranked {
+		reranked[i].Score = float64(len(reranked) - i)
+	}
+	return reranked, nil
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Query rewriters
+const (
+	RewriterNone      = "none"
+	RewriterHeuristic = "heuristic"
+	RewriterLLM       = "llm"
+)
+
+// rewriteHistoryTurns is the number of earlier questions the LLM rewriter
+// sees
+const rewriteHistoryTurns = 5
+
+// RewriteRequest is a conversational question with the context needed to
+// make it standalone
+type RewriteRequest struct {
+	Query        string
+	LastLocation string
+	Turns        []Turn
+	Resolver     *LocationResolver
+}
+
+// QueryRewriter turns a question that relies on the conversation, such as
+// "and the hotels there?", into a standalone search query
+type QueryRewriter interface {
+	Name() string
+	Rewrite(ctx context.Context, req RewriteRequest) (string, error)
+}
+
+// NewQueryRewriter creates the named rewriter. The LLM rewriter falls back
+// to the heuristic one when no provider is configured.
+func NewQueryRewriter(name string, provider LLMProvider) (QueryRewriter, error) {
+	switch strings.ToLower(name) {
+	case RewriterNone:
+		return noRewriter{}, nil
+	case "", RewriterHeuristic:
+		return HeuristicRewriter{}, nil
+	case RewriterLLM:
+		if provider == nil {
+			return HeuristicRewriter{}, nil
+		}
+		return &LLMRewriter{Provider: provider}, nil
+	default:
+		return nil, fmt.Errorf("unknown query rewriter %q", name)
+	}
+}
+
+// noRewriter searches with the question as asked
+type noRewriter struct{}
+
+func (noRewriter) Name() string { return RewriterNone }
+
+func (noRewriter) Rewrite(ctx context.Context, req RewriteRequest) (string, error) {
+	return req.Query, nil
+}
+
+// leadInPattern matches conversational openings that carry no meaning for
+// search
+var leadInPattern = regexp.MustCompile(`(?i)^\s*(?:(?:and|also|so|ok|okay|then)\b[\s,]*)*(?:(?:what|how)\s+about|what\s+of)?\s*`)
+
+// referencePattern matches words referring back to the location under
+// discussion. "there" and "here" only count as places: after "in" or "at"
+// or ending the question, as in "and the hotels there?".
+var referencePattern = regexp.MustCompile(`(?i)\b(?:(?:in|at)\s+(?:there|here)|(?:(?:in|at)\s+)?that\s+(?:place|state|county|city)|it)\b|\bthere$`)
+
+// existentialPattern matches "there" introducing something rather than
+// pointing at a place, as in "is there a beach?" or "there are no hotels"
+var existentialPattern = regexp.MustCompile(`(?i)\b(?:is|are|was|were)\s+there\b|\bthere\s+(?:is|are|was|were)\b|\bthere's\b`)
+
+// spaceBeforePunctuation matches the space left before punctuation when a
+// reference is dropped
+var spaceBeforePunctuation = regexp.MustCompile(`\s+([?.!,])`)
+
+// HeuristicRewriter rewrites follow-ups without an LLM: it drops lead-ins
+// such as "what about" and, when no location is named, replaces references
+// like "there" or "it" with the last location or appends it to topic-only
+// questions
+type HeuristicRewriter struct{}
+
+// Name identifies the rewriter
+func (HeuristicRewriter) Name() string {
+	return RewriterHeuristic
+}
+
+// Rewrite returns the standalone query
+func (HeuristicRewriter) Rewrite(ctx context.Context, req RewriteRequest) (string, error) {
+	query := strings.TrimSpace(leadInPattern.ReplaceAllString(req.Query, ""))
+	if query == "" {
+		return strings.TrimSpace(req.Query), nil
+	}
+	if req.LastLocation == "" || req.Resolver == nil {
+		return query, nil
+	}
+
+	// Questions naming a place or asking where to go are not about the
+	// last location
+	analysis := AnalyzeQuery(query, req.Resolver)
+	if len(analysis.Locations) > 0 || recommendPattern.MatchString(strings.ToLower(query)) {
+		return query, nil
+	}
+
+	// Replace the first reference with the location and drop the others
+	if references := locationReferences(query); len(references) > 0 {
+		var b strings.Builder
+		last := 0
+		for i, reference := range references {
+			b.WriteString(query[last:reference[0]])
+			if i == 0 {
+				if strings.EqualFold(query[reference[0]:reference[1]], "it") {
+					b.WriteString(req.LastLocation)
+				} else {
+					b.WriteString("in " + req.LastLocation)
+				}
+			}
+			last = reference[1]
+		}
+		b.WriteString(query[last:])
+		query = strings.Join(strings.Fields(b.String()), " ")
+		return spaceBeforePunctuation.ReplaceAllString(query, "$1"), nil
+	}
+
+	if analysis.IsFollowUp() {
+		question := strings.HasSuffix(query, "?")
+		query = strings.TrimRight(query, "?") + " in " + req.LastLocation
+		if question {
+			query += "?"
+		}
+	}
+	return query, nil
+}
+
+// locationReferences returns the positions of the references to the
+// location under discussion, leaving out "there" in existential phrases
+// such as "is there"
+func locationReferences(query string) [][]int {
+	body := strings.TrimRightFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("?.!", r)
+	})
+	existential := existentialPattern.FindAllStringIndex(body, -1)
+
+	var references [][]int
+	for _, reference := range referencePattern.FindAllStringIndex(body, -1) {
+		overlaps := false
+		for _, phrase := range existential {
+			if reference[0] < phrase[1] && phrase[0] < reference[1] {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// LLMRewriter asks the LLM to rewrite the question using the recent turns
+// of the conversation
+type LLMRewriter struct {
+	Provider LLMProvider
+}
+
+// Name identifies the rewriter
+func (r *LLMRewriter) Name() string {
+	return RewriterLLM
+}
+
+// Rewrite sends the recent questions and the locations they were about as
+// context and the question itself as the user message, and returns the
+// first line of the reply
+func (r *LLMRewriter) Rewrite(ctx context.Context, req RewriteRequest) (string, error) {
+	var system strings.Builder
+	system.WriteString("You rewrite the latest question of a conversation with a travel and tax information " +
+		"assistant into a standalone search query. Replace references such as \"there\" or \"it\" with the " +
+		"location they refer to and keep every location and topic the user asked about. " +
+		"Reply only with the rewritten query.")
+
+	turns := req.Turns
+	if len(turns) > rewriteHistoryTurns {
+		turns = turns[len(turns)-rewriteHistoryTurns:]
+	}
+	if len(turns) > 0 {
+		system.WriteString("\n\nEarlier questions:")
+		for _, turn := range turns {
+			fmt.Fprintf(&system, "\n- %s", turn.Question)
+			if turn.Location != "" {
+				fmt.Fprintf(&system, " (about %s)", turn.Location)
+			}
+		}
+	}
+	if req.LastLocation != "" {
+		fmt.Fprintf(&system, "\n\nThe conversation is currently about: %s", req.LastLocation)
+	}
+
+	reply, err := r.Provider.ChatCompletion(ctx, []ChatMessage{
+		{Role: RoleSystem, Content: system.String()},
+		{Role: RoleUser, Content: req.Query},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "Query:"))
+		line = strings.Trim(line, "\"'`")
+		if line != "" {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("empty query rewrite")
+}
+
+// rewriteQuery makes the question standalone with the session's rewriter,
+// falling back to the heuristic rewriter when it fails, and logs the result.
+// The first question of a conversation has nothing to refer back to and is
+// returned as it is.
+func rewriteQuery(ctx context.Context, session *Session, corpus *Corpus, query string) string {
+	if len(session.Turns) == 0 && session.LastLocation == "" {
+		return query
+	}
+
+	rewriter := session.Rewriter
+	if rewriter == nil {
+		rewriter = HeuristicRewriter{}
+	}
+	req := RewriteRequest{
+		Query:        query,
+		LastLocation: session.LastLocation,
+		Turns:        session.Turns,
+		Resolver:     corpus.Resolver,
+	}
+
+	rewritten, err := rewriter.Rewrite(ctx, req)
+	name := rewriter.Name()
+	if err != nil {
+		session.logf("query rewrite with %s failed: %v", name, err)
+		rewritten, _ = HeuristicRewriter{}.Rewrite(ctx, req)
+		name = RewriterHeuristic
+	}
+	session.logf("rewrote %q as %q (%s)", query, rewritten, name)
+	return rewritten
+}
@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	dataFile string
-	rootCmd  = &cobra.Command{
+	dataPaths    []string
+	manifestPath string
+	indexPath    string
+	sessionsDir  string
+	retention    time.Duration
+	rootCmd      = &cobra.Command{
 		Use:   "goragagent",
 		Short: "A tax information query system",
 		Long: `GoragAgent is a CLI tool that helps you query tax information
@@ -17,13 +23,31 @@ using natural language processing and AI to provide accurate answers.`,
 	}
 )
 
+// exitError ends the program with a status code once a command has
+// reported its own output
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var exit exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&dataFile, "data", "data/data1.csv", "path to the CSV data file")
+	rootCmd.PersistentFlags().StringSliceVar(&dataPaths, "data", []string{"data"}, "data files, directories or globs to load; a directory's manifest.yaml is used when present")
+	rootCmd.PersistentFlags().StringVar(&manifestPath, "manifest", "", "data manifest declaring every source, overriding --data")
+	rootCmd.PersistentFlags().StringVar(&sessionsDir, "sessions-dir", "sessions", "directory where named sessions are saved")
+	rootCmd.PersistentFlags().DurationVar(&retention, "session-retention", defaultSessionRetention, "how long an unused session is kept; 0 keeps sessions forever")
+	rootCmd.PersistentFlags().StringVar(&indexPath, "index", "index/goragagent.idx.json", "path to the on-disk vector index")
 }
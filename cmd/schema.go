@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Column types understood by schemas
+const (
+	ColumnString   = "string"
+	ColumnNumber   = "number"
+	ColumnPercent  = "percent"
+	ColumnCurrency = "currency"
+)
+
+// Default column names used when a schema does not declare its own
+const (
+	defaultKeyColumn    = "location"
+	defaultSourceColumn = "source"
+)
+
+// Schema declares the columns of a data source
+type Schema struct {
+	KeyColumn    string       `json:"key_column,omitempty" yaml:"key_column,omitempty"`
+	SourceColumn string       `json:"source_column,omitempty" yaml:"source_column,omitempty"`
+	Columns      []ColumnSpec `json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+// ColumnSpec declares a single column. Unit names the unit of number
+// columns, such as "km"; percent and currency columns carry their own.
+type ColumnSpec struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type,omitempty" yaml:"type,omitempty"`
+	Unit     string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Required bool   `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// defaultSchemas describe the built-in data types, used when a source does
+// not declare a schema
+var defaultSchemas = map[string]*Schema{
+	"tax": {Columns: []ColumnSpec{
+		{Name: "tax_rate", Type: ColumnPercent, Required: true},
+	}},
+	"county_tax": {Columns: []ColumnSpec{
+		{Name: parentField, Type: ColumnString, Required: true},
+		{Name: "tax_rate", Type: ColumnPercent, Required: true},
+	}},
+	"tourist": {Columns: []ColumnSpec{
+		{Name: "attractions", Type: ColumnString, Required: true},
+		{Name: "best_time", Type: ColumnString},
+	}},
+	"cost": {Columns: []ColumnSpec{
+		{Name: "daily_cost", Type: ColumnCurrency, Required: true},
+		{Name: "hotel_avg", Type: ColumnCurrency},
+		{Name: "food_avg", Type: ColumnCurrency},
+	}},
+}
+
+// schemaFor returns the declared or built-in schema of a source, or nil
+func schemaFor(source DataSource) *Schema {
+	if source.Schema != nil {
+		return source.Schema
+	}
+	return defaultSchemas[source.DataType]
+}
+
+// keyColumn returns the column holding the location
+func (s *Schema) keyColumn(source DataSource) string {
+	switch {
+	case source.KeyColumn != "":
+		return source.KeyColumn
+	case s != nil && s.KeyColumn != "":
+		return s.KeyColumn
+	}
+	return defaultKeyColumn
+}
+
+// sourceColumn returns the column citing where a row came from
+func (s *Schema) sourceColumn() string {
+	if s != nil && s.SourceColumn != "" {
+		return s.SourceColumn
+	}
+	return defaultSourceColumn
+}
+
+// Validate checks that the schema only uses known column types
+func (s *Schema) Validate() error {
+	if s == nil {
+		return nil
+	}
+	for _, column := range s.Columns {
+		if column.Name == "" {
+			return fmt.Errorf("schema column without a name")
+		}
+		switch column.Type {
+		case "", ColumnString, ColumnNumber, ColumnPercent, ColumnCurrency:
+		default:
+			return fmt.Errorf("column %s has unknown type %q", column.Name, column.Type)
+		}
+	}
+	return nil
+}
+
+// checkHeaders reports required columns missing from the headers
+func (s *Schema) checkHeaders(headers []string) error {
+	if s == nil {
+		return nil
+	}
+	for _, column := range s.Columns {
+		if column.Required && columnIndex(headers, column.Name) < 0 {
+			return fmt.Errorf("missing required column %q", column.Name)
+		}
+	}
+	return nil
+}
+
+// columnError is a problem with the value of one column
+type columnError struct {
+	column string
+	err    error
+}
+
+func (e *columnError) Error() string {
+	return fmt.Sprintf("column %s: %v", e.column, e.err)
+}
+
+// parseValues checks the values of one row against the schema and returns
+// the typed numeric values
+func (s *Schema) parseValues(values map[string]string) (map[string]NumericValue, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	var numbers map[string]NumericValue
+	for _, column := range s.Columns {
+		raw := strings.TrimSpace(values[column.Name])
+		if raw == "" {
+			if column.Required {
+				return nil, &columnError{column: column.Name, err: errors.New("missing required value")}
+			}
+			continue
+		}
+		if column.Type == "" || column.Type == ColumnString {
+			continue
+		}
+
+		value, err := parseTyped(raw, column)
+		if err != nil {
+			return nil, &columnError{column: column.Name, err: err}
+		}
+		if numbers == nil {
+			numbers = make(map[string]NumericValue)
+		}
+		numbers[column.Name] = value
+	}
+	return numbers, nil
+}
+
+// parseTyped parses a raw value as the column's type
+func parseTyped(raw string, column ColumnSpec) (NumericValue, error) {
+	value, ok := ParseNumeric(raw)
+	if !ok {
+		return NumericValue{}, fmt.Errorf("expected a %s, got %q", column.Type, raw)
+	}
+
+	switch column.Type {
+	case ColumnPercent:
+		if value.Unit == UnitCurrency {
+			return NumericValue{}, fmt.Errorf("expected a percent, got %q", raw)
+		}
+		value.Unit = UnitPercent
+	case ColumnCurrency:
+		if value.Unit == UnitPercent {
+			return NumericValue{}, fmt.Errorf("expected a currency amount, got %q", raw)
+		}
+		value.Unit = UnitCurrency
+	case ColumnNumber:
+		if value.Unit != "" {
+			return NumericValue{}, fmt.Errorf("expected a number, got %q", raw)
+		}
+		value.Unit = column.Unit
+	}
+	return value, nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultMemorySize is the number of interactions a session remembers
+const defaultMemorySize = 5
+
+// Interaction stores a user interaction
+type Interaction struct {
+	Location  string
+	Question  string
+	Timestamp time.Time
+}
+
+// Session holds the state of one conversation: the provider answering it,
+// the last question and location, the recent interactions and every turn
+// so far. Sessions share nothing, so several conversations can run in one
+// process; a single session is not safe for concurrent use. Named sessions
+// can be stored and resumed with a SessionStore.
+type Session struct {
+	Name         string
+	Provider     LLMProvider
+	LastQuery    string
+	LastLocation string
+	// QueryLocation is the location the latest question was answered
+	// about, empty when it was about none
+	QueryLocation string
+	Interactions  []Interaction // most recent first
+	MemorySize    int
+	Turns         []Turn
+	// HistoryTokens is the token budget for earlier turns sent to the provider
+	HistoryTokens int
+	// Rewriter makes follow-up questions standalone before searching; nil
+	// uses the HeuristicRewriter
+	Rewriter QueryRewriter
+	// Classifier decides how each question is answered; nil uses the
+	// RuleClassifier
+	Classifier IntentClassifier
+	// Log receives debugging output such as rewritten queries; nil
+	// discards it
+	Log io.Writer
+}
+
+// NewSession starts a conversation answered by provider, which may be nil
+// for basic mode
+func NewSession(provider LLMProvider) *Session {
+	return &Session{Provider: provider, MemorySize: defaultMemorySize, HistoryTokens: defaultHistoryTokens}
+}
+
+// RecordTurn adds an answered question, the location it was about, empty
+// for none, and the information retrieved for it to the history and
+// returns the turn
+func (s *Session) RecordTurn(question, location, info, answer string) Turn {
+	turn := Turn{Time: time.Now(), Question: question, Location: location, Context: info, Answer: answer}
+	s.Turns = append(s.Turns, turn)
+	s.LastQuery = question
+	return turn
+}
+
+// replay restores the state a stored turn left the session in
+func (s *Session) replay(turn Turn) {
+	s.Turns = append(s.Turns, turn)
+	s.LastQuery = turn.Question
+	if turn.Location != "" {
+		s.LastLocation = turn.Location
+		s.remember(Interaction{Location: turn.Location, Question: turn.Question, Timestamp: turn.Time})
+	}
+}
+
+// rememberLocations records a question about several locations, keeping
+// the first one as the current location
+func (s *Session) rememberLocations(locations []string, question string) {
+	for _, location := range locations {
+		s.addInteraction(location, question)
+	}
+	s.LastLocation = locations[0]
+	s.QueryLocation = locations[0]
+}
+
+// addInteraction adds a new interaction to the memory
+func (s *Session) addInteraction(location, question string) {
+	s.remember(Interaction{
+		Location:  location,
+		Question:  question,
+		Timestamp: time.Now(),
+	})
+}
+
+// remember adds an interaction, forgetting the oldest past MemorySize
+func (s *Session) remember(interaction Interaction) {
+	// Add to the beginning of the slice
+	s.Interactions = append([]Interaction{interaction}, s.Interactions...)
+
+	// Keep only the last MemorySize interactions
+	if s.MemorySize > 0 && len(s.Interactions) > s.MemorySize {
+		s.Interactions = s.Interactions[:s.MemorySize]
+	}
+}
+
+// memoryInfo returns a formatted string of recent interactions
+func (s *Session) memoryInfo() string {
+	if len(s.Interactions) == 0 {
+		return "You haven't asked about any locations yet."
+	}
+
+	var result strings.Builder
+	result.WriteString("Recent locations you've asked about:\n")
+
+	// Create a map to track unique locations
+	uniqueLocations := make(map[string]bool)
+
+	for _, interaction := range s.Interactions {
+		uniqueLocations[interaction.Location] = true
+	}
+
+	// List unique locations
+	result.WriteString("\nUnique locations discussed:\n")
+	for location := range uniqueLocations {
+		result.WriteString(fmt.Sprintf("- %s\n", location))
+	}
+
+	// Show last interaction details
+	result.WriteString(fmt.Sprintf("\nMost recent query was about: %s\n", s.Interactions[0].Location))
+
+	return result.String()
+}
+
+// logf writes a line of debugging output
+func (s *Session) logf(format string, args ...any) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultSessionRetention is how long an unused session is kept
+const defaultSessionRetention = 30 * 24 * time.Hour
+
+// sessionNamePattern keeps session names usable as file names
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Turn is one question of a conversation, the information retrieved for
+// it and its answer
+type Turn struct {
+	Time     time.Time `json:"time"`
+	Question string    `json:"question"`
+	Location string    `json:"location,omitempty"`
+	Context  string    `json:"context,omitempty"`
+	Answer   string    `json:"answer"`
+}
+
+// SessionInfo summarizes a stored session
+type SessionInfo struct {
+	Name    string
+	Turns   int
+	Updated time.Time
+}
+
+// SessionStore persists sessions as one JSONL file of turns per session.
+// Sessions unused for longer than Retention are pruned; zero keeps them
+// forever.
+type SessionStore struct {
+	Dir       string
+	Retention time.Duration
+}
+
+// NewSessionStore stores sessions under dir
+func NewSessionStore(dir string, retention time.Duration) *SessionStore {
+	return &SessionStore{Dir: dir, Retention: retention}
+}
+
+// path returns the file of a session, rejecting names that are not plain
+// file names
+func (s *SessionStore) path(name string) (string, error) {
+	if !sessionNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid session name %q: use up to 64 letters, digits, '-' or '_'", name)
+	}
+	return filepath.Join(s.Dir, name+".jsonl"), nil
+}
+
+// Open resumes a stored session, or starts a new one when none exists
+func (s *SessionStore) Open(name string, provider LLMProvider) (*Session, error) {
+	turns, err := s.Turns(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	session := NewSession(provider)
+	session.Name = name
+	for _, turn := range turns {
+		session.replay(turn)
+	}
+	return session, nil
+}
+
+// Turns reads every turn of a stored session in order
+func (s *SessionStore) Turns(name string) ([]Turn, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("session %q not found: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("error opening session %s: %v", name, err)
+	}
+	defer file.Close()
+
+	var turns []Turn
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var turn Turn
+		if err := json.Unmarshal(scanner.Bytes(), &turn); err != nil {
+			return nil, fmt.Errorf("invalid session %s at line %d: %v", name, line, err)
+		}
+		turns = append(turns, turn)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading session %s: %v", name, err)
+	}
+	return turns, nil
+}
+
+// Append adds a turn to the end of a session's file
+func (s *SessionStore) Append(name string, turn Turn) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("error creating sessions directory: %v", err)
+	}
+
+	data, err := json.Marshal(turn)
+	if err != nil {
+		return fmt.Errorf("error encoding turn: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing session: %v", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing session: %v", err)
+	}
+	return file.Close()
+}
+
+// SessionErrors collects the errors of session files that could not be
+// read. Listing skips them so one bad file does not hide the others.
+type SessionErrors []error
+
+func (e SessionErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e[0], len(e)-1)
+}
+
+func (e SessionErrors) Unwrap() []error {
+	return e
+}
+
+// List returns the stored sessions, most recently used first. Sessions
+// that cannot be read are skipped and reported as SessionErrors along
+// with the others.
+func (s *SessionStore) List() ([]SessionInfo, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading sessions directory: %v", err)
+	}
+
+	var sessions []SessionInfo
+	var errs SessionErrors
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if entry.IsDir() || !ok || !sessionNamePattern.MatchString(name) {
+			continue
+		}
+		turns, err := s.Turns(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		info := SessionInfo{Name: name, Turns: len(turns)}
+		if len(turns) > 0 {
+			info.Updated = turns[len(turns)-1].Time
+		} else if stat, err := entry.Info(); err == nil {
+			info.Updated = stat.ModTime()
+		}
+		sessions = append(sessions, info)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Updated.After(sessions[j].Updated)
+	})
+	if len(errs) > 0 {
+		return sessions, errs
+	}
+	return sessions, nil
+}
+
+// Delete removes a stored session
+func (s *SessionStore) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("session %q not found", name)
+		}
+		return fmt.Errorf("error deleting session: %v", err)
+	}
+	return nil
+}
+
+// Prune deletes the sessions last used before now minus the retention,
+// except those named in keep, and returns their names. Sessions that
+// cannot be read are left alone and reported as SessionErrors.
+func (s *SessionStore) Prune(now time.Time, keep ...string) ([]string, error) {
+	if s.Retention <= 0 {
+		return nil, nil
+	}
+	sessions, err := s.List()
+	var skipped SessionErrors
+	if err != nil && !errors.As(err, &skipped) {
+		return nil, err
+	}
+
+	var pruned []string
+	for _, session := range sessions {
+		if now.Sub(session.Updated) <= s.Retention || slices.Contains(keep, session.Name) {
+			continue
+		}
+		if err := s.Delete(session.Name); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, session.Name)
+	}
+	if len(skipped) > 0 {
+		return pruned, skipped
+	}
+	return pruned, nil
+}
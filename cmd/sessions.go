@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved query sessions",
+	Long: `List, show and delete the sessions saved by "query --session NAME".
+Sessions unused for longer than --session-retention are pruned.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved sessions, most recently used first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, err := configuredSessionStore().List()
+		if err := skippedSessions(cmd, err); err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "No saved sessions")
+			return nil
+		}
+		for _, session := range sessions {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\tlast used %s\n",
+				session.Name, count(session.Turns, "question"), session.Updated.Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show NAME",
+	Short: "Show the questions and answers of a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		turns, err := configuredSessionStore().Turns(args[0])
+		if err != nil {
+			return err
+		}
+		for _, turn := range turns {
+			fmt.Fprintf(cmd.OutOrStdout(), "[%s] > %s\n%s\n\n",
+				turn.Time.Local().Format("2006-01-02 15:04"), turn.Question, turn.Answer)
+		}
+		return nil
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete NAME",
+	Short: "Delete a saved session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := configuredSessionStore().Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Deleted session %s\n", args[0])
+		return nil
+	},
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete sessions unused for longer than --session-retention",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pruned, err := configuredSessionStore().Prune(time.Now())
+		if err := skippedSessions(cmd, err); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Pruned %s\n", count(len(pruned), "session"))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsDeleteCmd, sessionsPruneCmd)
+}
+
+// skippedSessions warns about each session file that could not be read
+// and returns any other error
+func skippedSessions(cmd *cobra.Command, err error) error {
+	var skipped SessionErrors
+	if !errors.As(err, &skipped) {
+		return err
+	}
+	for _, err := range skipped {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: skipped %v\n", err)
+	}
+	return nil
+}
+
+// configuredSessionStore returns the session store selected by the
+// --sessions-dir and --session-retention flags
+func configuredSessionStore() *SessionStore {
+	return NewSessionStore(sessionsDir, retention)
+}
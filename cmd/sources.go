@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DataSource describes one data file to load
+type DataSource struct {
+	Path      string  `json:"path" yaml:"path"`
+	DataType  string  `json:"data_type" yaml:"data_type"`
+	KeyColumn string  `json:"key_column,omitempty" yaml:"key_column,omitempty"`
+	Schema    *Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+}
+
+// Manifest lists the data sources of a deployment
+type Manifest struct {
+	Sources []DataSource `json:"sources" yaml:"sources"`
+}
+
+// manifestNames are looked up when a data directory is given
+var manifestNames = []string{"manifest.yaml", "manifest.yml", "manifest.json"}
+
+// validateManifestPath applies the data file security checks to a manifest
+func validateManifestPath(path string) error {
+	return validatePath(path, ".yaml", ".yml", ".json")
+}
+
+// LoadManifest reads a YAML or JSON manifest. Relative source paths are
+// resolved against the manifest's directory.
+func LoadManifest(path string) (*Manifest, error) {
+	if err := validateManifestPath(path); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %v", err)
+	}
+
+	var manifest Manifest
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &manifest)
+	} else {
+		err = yaml.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i, source := range manifest.Sources {
+		if source.Path == "" {
+			return nil, fmt.Errorf("invalid manifest %s: source %d has no path", path, i+1)
+		}
+		if !filepath.IsAbs(source.Path) {
+			manifest.Sources[i].Path = filepath.Join(dir, source.Path)
+		}
+		if source.DataType == "" {
+			manifest.Sources[i].DataType = inferDataType(source.Path)
+		}
+	}
+	return &manifest, nil
+}
+
+// inferDataType derives the DataType of a file without a manifest entry,
+// using the built-in data files first and the file name otherwise
+func inferDataType(path string) string {
+	for dataType, file := range dataFiles {
+		if filepath.Base(file) == filepath.Base(path) {
+			return dataType
+		}
+	}
+	base := filepath.Base(path)
+	switch filepath.Ext(base) {
+	case ".md", ".txt", ".pdf":
+		return DataTypeDocument
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// isDataFile reports whether a file found in a directory or glob has a
+// registered loader. Manifests are never loaded as data.
+func isDataFile(path string) bool {
+	base := filepath.Base(path)
+	for _, name := range manifestNames {
+		if base == name {
+			return false
+		}
+	}
+	_, ok := loaders[strings.ToLower(filepath.Ext(path))]
+	return ok && !strings.HasPrefix(base, ".")
+}
+
+// ResolveSources expands data paths into sources. Each path may be a file,
+// a directory (using its manifest if present, else every data file in it)
+// or a glob. An explicit manifest replaces the path arguments.
+func ResolveSources(paths []string, manifestPath string) ([]DataSource, error) {
+	if manifestPath != "" {
+		manifest, err := LoadManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		return manifest.Sources, nil
+	}
+
+	var sources []DataSource
+	seen := make(map[string]bool)
+	add := func(source DataSource) {
+		key := filepath.Clean(source.Path)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		sources = append(sources, source)
+	}
+
+	for _, path := range paths {
+		if strings.ContainsAny(path, "*?[") {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %v", path, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no data files match %q", path)
+			}
+			sort.Strings(matches)
+			for _, match := range matches {
+				if isDataFile(match) {
+					add(DataSource{Path: match, DataType: inferDataType(match)})
+				}
+			}
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading data path: %v", err)
+		}
+		if !info.IsDir() {
+			add(DataSource{Path: path, DataType: inferDataType(path)})
+			continue
+		}
+
+		dirSources, err := directorySources(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range dirSources {
+			add(source)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no data files found in %s", strings.Join(paths, ", "))
+	}
+	return sources, nil
+}
+
+// directorySources returns the sources of a data directory
+func directorySources(dir string) ([]DataSource, error) {
+	for _, name := range manifestNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			manifest, err := LoadManifest(path)
+			if err != nil {
+				return nil, err
+			}
+			return manifest.Sources, nil
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading data directory: %v", err)
+	}
+
+	var sources []DataSource
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() && isDataFile(path) {
+			sources = append(sources, DataSource{Path: path, DataType: inferDataType(path)})
+		}
+	}
+	return sources, nil
+}
+
+// configuredSources resolves the --data and --manifest flags
+func configuredSources() ([]DataSource, error) {
+	return ResolveSources(dataPaths, manifestPath)
+}
@@ -0,0 +1,435 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchemaVersion is bumped whenever the tables change. Stores created
+// with another version are rebuilt.
+const sqliteSchemaVersion = "3"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS records (
+	id         INTEGER PRIMARY KEY,
+	location   TEXT NOT NULL,
+	data_type  TEXT NOT NULL,
+	source     TEXT NOT NULL,
+	effective  TEXT,
+	valid      TEXT,
+	modified   TEXT,
+	numbers    TEXT,
+	provenance TEXT,
+	tags       TEXT
+);
+CREATE INDEX IF NOT EXISTS records_location ON records(location);
+CREATE TABLE IF NOT EXISTS record_values (
+	record_id INTEGER NOT NULL REFERENCES records(id),
+	field     TEXT NOT NULL,
+	value     TEXT NOT NULL,
+	PRIMARY KEY (record_id, field)
+);
+CREATE TABLE IF NOT EXISTS chunks (
+	id        INTEGER PRIMARY KEY,
+	record_id INTEGER NOT NULL REFERENCES records(id),
+	text      TEXT NOT NULL,
+	vector    BLOB NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS records_fts USING fts5(text);
+`
+
+// SQLiteStore persists records, their values, provenance and embedded
+// chunks in a SQLite database with an FTS5 full-text index. Record ids are
+// their position in the record set plus one.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens or creates the database at path
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("error creating store directory: %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening store: %v", err)
+	}
+	// A single connection keeps in-memory databases shared and writes serial
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLiteStore(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating store schema: %v", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// migrateSQLiteStore creates the tables, dropping those of an older schema
+// version first. Their records are rewritten by the next Replace.
+func migrateSQLiteStore(db *sql.DB) error {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	var version string
+	err := db.QueryRow(`SELECT value FROM meta WHERE key = 'schema_version'`).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if version == sqliteSchemaVersion {
+		return nil
+	}
+
+	for _, statement := range []string{
+		`DROP TABLE IF EXISTS records_fts`,
+		`DROP TABLE IF EXISTS chunks`,
+		`DROP TABLE IF EXISTS record_values`,
+		`DROP TABLE IF EXISTS records`,
+		`DELETE FROM meta`,
+		sqliteSchema,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec(`INSERT INTO meta (key, value) VALUES ('schema_version', ?)`, sqliteSchemaVersion)
+	return err
+}
+
+// Name identifies the backend
+func (s *SQLiteStore) Name() string {
+	return StoreSQLite
+}
+
+// Version returns the version stored by the last Replace, empty when the
+// store is empty
+func (s *SQLiteStore) Version() (string, error) {
+	var version string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = 'version'`).Scan(&version)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("error reading store: %v", err)
+	}
+	return version, nil
+}
+
+// Replace rewrites the stored records and chunks. Nothing is written when
+// the store already holds the same version.
+func (s *SQLiteStore) Replace(records []Record, chunks []VectorChunk, version string) error {
+	if version != "" {
+		stored, err := s.Version()
+		if err != nil {
+			return err
+		}
+		if stored == version {
+			return nil
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range []string{`DELETE FROM chunks`, `DELETE FROM record_values`, `DELETE FROM records`, `DELETE FROM records_fts`} {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("error writing store: %v", err)
+		}
+	}
+
+	insertRecord, err := tx.Prepare(`INSERT INTO records (id, location, data_type, source, effective, valid, modified, numbers, provenance, tags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	insertValue, err := tx.Prepare(`INSERT INTO record_values (record_id, field, value) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	insertText, err := tx.Prepare(`INSERT INTO records_fts (rowid, text) VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	insertChunk, err := tx.Prepare(`INSERT INTO chunks (record_id, text, vector) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+
+	for i, record := range records {
+		id := i + 1
+		numbers, err := nullJSON(record.Numbers, len(record.Numbers) == 0)
+		if err != nil {
+			return err
+		}
+		provenance, err := nullJSON(record.Provenance, record.Provenance == nil)
+		if err != nil {
+			return err
+		}
+		tags, err := nullJSON(record.Tags, len(record.Tags) == 0)
+		if err != nil {
+			return err
+		}
+
+		if _, err := insertRecord.Exec(id, record.Location, record.DataType, record.Source,
+			nullDate(record.EffectiveDate), nullDate(record.ValidUntil), nullTime(record.Modified), numbers, provenance, tags); err != nil {
+			return fmt.Errorf("error writing record %d: %v", id, err)
+		}
+		for field, value := range record.Values {
+			if _, err := insertValue.Exec(id, field, value); err != nil {
+				return fmt.Errorf("error writing record %d: %v", id, err)
+			}
+		}
+		if _, err := insertText.Exec(id, RecordText(record)); err != nil {
+			return fmt.Errorf("error indexing record %d: %v", id, err)
+		}
+	}
+
+	for _, chunk := range chunks {
+		if _, err := insertChunk.Exec(chunk.Record+1, chunk.Text, encodeVector(chunk.Vector)); err != nil {
+			return fmt.Errorf("error writing chunk of record %d: %v", chunk.Record+1, err)
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('version', ?)`, version); err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	return nil
+}
+
+// nullJSON encodes a value as JSON, or NULL when empty
+func nullJSON(value any, empty bool) (sql.NullString, error) {
+	if empty {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("error encoding record: %v", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// nullDate formats a date, or NULL when unset
+func nullDate(date time.Time) sql.NullString {
+	if date.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: date.Format(time.RFC3339), Valid: true}
+}
+
+// nullTime formats a time to the nanosecond, or NULL when unset
+func nullTime(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.Format(time.RFC3339Nano), Valid: true}
+}
+
+// encodeVector packs a vector as little-endian float32s
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+// decodeVector unpacks a vector written by encodeVector
+func decodeVector(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
+
+// Records returns every record in load order
+func (s *SQLiteStore) Records() ([]Record, error) {
+	records, _, err := s.query(`1 = 1`)
+	return records, err
+}
+
+// Chunks returns the embedded chunks of the records in the order written
+func (s *SQLiteStore) Chunks() ([]VectorChunk, error) {
+	rows, err := s.db.Query(`SELECT record_id, text, vector FROM chunks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading store: %v", err)
+	}
+	defer rows.Close()
+
+	var chunks []VectorChunk
+	for rows.Next() {
+		var id int
+		var chunk VectorChunk
+		var vector []byte
+		if err := rows.Scan(&id, &chunk.Text, &vector); err != nil {
+			return nil, fmt.Errorf("error reading store: %v", err)
+		}
+		chunk.Record = id - 1
+		chunk.Vector = decodeVector(vector)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, rows.Err()
+}
+
+// ByLocation returns the records of a location and the documents tagged
+// with it in load order
+func (s *SQLiteStore) ByLocation(location string) ([]Record, error) {
+	records, _, err := s.query(`location = ? OR EXISTS (SELECT 1 FROM json_each(records.tags) WHERE value = ?)`, location, location)
+	return records, err
+}
+
+// Search ranks records with the FTS5 BM25 function over their text
+func (s *SQLiteStore) Search(query string, k int) ([]ScoredRecord, error) {
+	// Folded terms are matched as prefixes so "beaches" finds "beach"
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+	if k <= 0 {
+		k = -1
+	}
+
+	match := strings.Join(quoted, " OR ")
+	ranked := `SELECT rowid, bm25(records_fts) FROM records_fts
+		WHERE records_fts MATCH ? ORDER BY bm25(records_fts), rowid LIMIT ?`
+
+	rows, err := s.db.Query(ranked, match, k)
+	if err != nil {
+		return nil, fmt.Errorf("error searching store: %v", err)
+	}
+
+	scores := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var rank float64
+		if err := rows.Scan(&id, &rank); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error searching store: %v", err)
+		}
+		// FTS5 ranks better matches with lower, negative values
+		scores[id] = -rank
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error searching store: %v", err)
+	}
+	if len(scores) == 0 {
+		return nil, nil
+	}
+
+	records, recordIDs, err := s.query(`id IN (SELECT rowid FROM (`+ranked+`))`, match, k)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ScoredRecord, len(records))
+	for i, record := range records {
+		results[i] = ScoredRecord{Record: record, Index: recordIDs[i] - 1, Score: scores[recordIDs[i]]}
+	}
+	sortScored(results)
+	return results, nil
+}
+
+// query loads the records matching a condition on the records table, with
+// their values, in id order
+func (s *SQLiteStore) query(condition string, args ...any) ([]Record, []int, error) {
+	rows, err := s.db.Query(`SELECT id, location, data_type, source, effective, valid, modified, numbers, provenance, tags
+		FROM records WHERE `+condition+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading store: %v", err)
+	}
+
+	var records []Record
+	var ids []int
+	positions := make(map[int]int)
+	for rows.Next() {
+		var id int
+		var record Record
+		var effective, valid, modified, numbers, provenance, tags sql.NullString
+		if err := rows.Scan(&id, &record.Location, &record.DataType, &record.Source, &effective, &valid, &modified, &numbers, &provenance, &tags); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("error reading store: %v", err)
+		}
+		for _, date := range []struct {
+			column sql.NullString
+			target *time.Time
+		}{{effective, &record.EffectiveDate}, {valid, &record.ValidUntil}, {modified, &record.Modified}} {
+			if !date.column.Valid {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339Nano, date.column.String)
+			if err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("error decoding record %d: %v", id, err)
+			}
+			*date.target = parsed
+		}
+		record.Values = make(map[string]string)
+		for _, field := range []struct {
+			column sql.NullString
+			target any
+		}{{numbers, &record.Numbers}, {provenance, &record.Provenance}, {tags, &record.Tags}} {
+			if !field.column.Valid {
+				continue
+			}
+			if err := json.Unmarshal([]byte(field.column.String), field.target); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("error decoding record %d: %v", id, err)
+			}
+		}
+		positions[id] = len(records)
+		records = append(records, record)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading store: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, nil
+	}
+
+	values, err := s.db.Query(`SELECT record_id, field, value FROM record_values
+		WHERE record_id IN (SELECT id FROM records WHERE `+condition+`)`, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading store: %v", err)
+	}
+	defer values.Close()
+	for values.Next() {
+		var id int
+		var field, value string
+		if err := values.Scan(&id, &field, &value); err != nil {
+			return nil, nil, fmt.Errorf("error reading store: %v", err)
+		}
+		if i, ok := positions[id]; ok {
+			records[i].Values[field] = value
+		}
+	}
+	return records, ids, values.Err()
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
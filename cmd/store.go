@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Record store backends
+const (
+	StoreMemory = "memory"
+	StoreSQLite = "sqlite"
+)
+
+// RecordStore holds the record set with its embedded chunks and answers
+// location lookups and full-text searches. Location lookups include the
+// documents tagged with the location. Search results and
+// VectorChunk.Record index into Records. Version identifies the sources
+// the records were loaded from, so persistent stores holding the current
+// sources are queried without loading them again.
+type RecordStore interface {
+	Name() string
+	Version() (string, error)
+	Replace(records []Record, chunks []VectorChunk, version string) error
+	Records() ([]Record, error)
+	Chunks() ([]VectorChunk, error)
+	ByLocation(location string) ([]Record, error)
+	Search(query string, k int) ([]ScoredRecord, error)
+	Close() error
+}
+
+// MemoryStore keeps records in memory with a location index and BM25 search
+type MemoryStore struct {
+	records    []Record
+	chunks     []VectorChunk
+	version    string
+	byLocation map[string][]int
+	lexical    *BM25Index
+}
+
+// NewMemoryStore creates an in-memory store holding the records
+func NewMemoryStore(records []Record) *MemoryStore {
+	store := &MemoryStore{}
+	store.Replace(records, nil, "")
+	return store
+}
+
+// Name identifies the backend
+func (s *MemoryStore) Name() string {
+	return StoreMemory
+}
+
+// Version returns the version given to the last Replace
+func (s *MemoryStore) Version() (string, error) {
+	return s.version, nil
+}
+
+// Replace swaps the stored records
+func (s *MemoryStore) Replace(records []Record, chunks []VectorChunk, version string) error {
+	s.records = records
+	s.chunks = chunks
+	s.version = version
+	s.byLocation = make(map[string][]int)
+	for i, record := range records {
+		locations := record.Tags
+		if !isDocument(record) || len(locations) == 0 {
+			locations = []string{record.Location}
+		}
+		for _, location := range locations {
+			if location != "" {
+				s.byLocation[location] = append(s.byLocation[location], i)
+			}
+		}
+	}
+	s.lexical = NewBM25Index(records)
+	return nil
+}
+
+// Records returns every record in load order
+func (s *MemoryStore) Records() ([]Record, error) {
+	return s.records, nil
+}
+
+// Chunks returns the embedded chunks of the records
+func (s *MemoryStore) Chunks() ([]VectorChunk, error) {
+	return s.chunks, nil
+}
+
+// ByLocation returns the records of a location and the documents tagged
+// with it in load order
+func (s *MemoryStore) ByLocation(location string) ([]Record, error) {
+	var result []Record
+	for _, i := range s.byLocation[location] {
+		result = append(result, s.records[i])
+	}
+	return result, nil
+}
+
+// Search runs BM25 over the records
+func (s *MemoryStore) Search(query string, k int) ([]ScoredRecord, error) {
+	return s.lexical.Search(query, k), nil
+}
+
+// Close releases nothing
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// OpenStore opens the record store backend selected by name
+func OpenStore(name, path string) (RecordStore, error) {
+	switch strings.ToLower(name) {
+	case "", StoreMemory:
+		return NewMemoryStore(nil), nil
+	case StoreSQLite:
+		return OpenSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown record store %q", name)
+	}
+}
+
+// SourcesVersion identifies the sources by their configuration and content
+// hash, together with the embedder and aliases a corpus is built with, so
+// a store holding the corpus loaded from them can be reused as it is
+func SourcesVersion(sources []DataSource, embedder Embedder, aliases map[string]string) (string, error) {
+	type versionedSource struct {
+		DataSource
+		Hash string `json:"hash"`
+	}
+	hashed := make([]versionedSource, len(sources))
+	for i, source := range sources {
+		hash, err := hashFile(source.Path)
+		if err != nil {
+			return "", err
+		}
+		hashed[i] = versionedSource{DataSource: source, Hash: hash}
+	}
+
+	data, err := json.Marshal(struct {
+		Version    int               `json:"version"`
+		Embedder   string            `json:"embedder"`
+		Dimensions int               `json:"dimensions"`
+		Aliases    map[string]string `json:"aliases"`
+		Sources    []versionedSource `json:"sources"`
+	}{indexFormatVersion, embedder.Name(), embedder.Dimensions(), aliases, hashed})
+	if err != nil {
+		return "", fmt.Errorf("error versioning sources: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// OpenStoredCorpus builds a corpus from the records and chunks held by a
+// store, querying the store instead of an in-memory copy
+func OpenStoredCorpus(store RecordStore, embedder Embedder, aliases map[string]string) (*Corpus, error) {
+	records, err := store.Records()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records stored")
+	}
+	chunks, err := store.Chunks()
+	if err != nil {
+		return nil, err
+	}
+	return newCorpus(records, NewVectorIndex(embedder, records, chunks), aliases, store), nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// ValidationIssue is one problem found in a data file. Row and Column are
+// set when the problem is in a row.
+type ValidationIssue struct {
+	File    string `json:"file"`
+	Row     int    `json:"row,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationReport lists every problem found in a set of data files
+type ValidationReport struct {
+	Files   int               `json:"files"`
+	Records int               `json:"records"`
+	Valid   bool              `json:"valid"`
+	Issues  []ValidationIssue `json:"issues"`
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [paths]",
+	Short: "Check data files for errors without starting a session",
+	Long: `Load every data file with the same path, row and schema checks as the
+query command and report all errors found, not just the first. Paths may be
+files, directories or globs and default to --data and --manifest. Exits with
+status 1 when any error is found.`,
+	RunE: runValidate,
+	// The report is the output; the exit status error needs no usage or
+	// message of its own
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().String("format", "text", "report format: text or json")
+}
+
+// ValidateSources loads every source and collects all of their errors
+func ValidateSources(sources []DataSource) ValidationReport {
+	report := ValidationReport{Files: len(sources), Issues: []ValidationIssue{}}
+	for _, source := range sources {
+		records, err := LoadSource(source)
+		report.Records += len(records)
+		if err == nil {
+			continue
+		}
+
+		var errs LoadErrors
+		if !errors.As(err, &errs) {
+			errs = LoadErrors{err}
+		}
+		for _, err := range errs {
+			issue := ValidationIssue{File: source.Path, Message: err.Error()}
+			var row *RowError
+			if errors.As(err, &row) {
+				issue.Row, issue.Column, issue.Message = row.Row, row.Column, row.Err.Error()
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	report.Valid = len(report.Issues) == 0
+	return report
+}
+
+// WriteText writes the report as one line per issue followed by a summary
+func (r ValidationReport) WriteText(w io.Writer) {
+	for _, issue := range r.Issues {
+		location := issue.File
+		if issue.Row > 0 {
+			location += fmt.Sprintf(": row %d", issue.Row)
+		}
+		if issue.Column != "" {
+			location += ", column " + issue.Column
+		}
+		fmt.Fprintf(w, "%s: %s\n", location, issue.Message)
+	}
+
+	summary := "no errors found"
+	if len(r.Issues) > 0 {
+		summary = count(len(r.Issues), "error") + " found"
+	}
+	fmt.Fprintf(w, "Checked %s, %s: %s\n", count(r.Files, "file"), count(r.Records, "record"), summary)
+}
+
+// count formats a number followed by a noun, pluralized with "s"
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q: must be text or json", format)
+	}
+
+	sources, err := configuredSources()
+	if len(args) > 0 {
+		sources, err = ResolveSources(args, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	report := ValidateSources(sources)
+	out := cmd.OutOrStdout()
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		report.WriteText(out)
+	}
+
+	if !report.Valid {
+		return exitError{code: 1}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScoredRecord is a retrieval hit. Index points into the searched record set.
+type ScoredRecord struct {
+	Record Record
+	Index  int
+	Score  float64
+}
+
+// minSimilarity is the cosine similarity below which a hit is ignored
+const minSimilarity = 0.05
+
+// RecordText renders a record as plain text for embedding
+func RecordText(record Record) string {
+	var b strings.Builder
+	b.WriteString(record.Location)
+
+	keys := make([]string, 0, len(record.Values))
+	for key := range record.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if record.Values[key] == "" {
+			continue
+		}
+		b.WriteString("\n")
+		b.WriteString(strings.ReplaceAll(key, "_", " "))
+		b.WriteString(": ")
+		b.WriteString(record.Values[key])
+	}
+
+	if record.Source != "" {
+		b.WriteString("\nsource: ")
+		b.WriteString(record.Source)
+	}
+	return b.String()
+}
+
+// Chunking limits for long record texts
+const (
+	maxChunkWords     = 200
+	chunkOverlapWords = 40
+)
+
+// chunkText splits text into overlapping word windows. Short texts are
+// returned as a single chunk.
+func chunkText(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) <= size {
+		return []string{text}
+	}
+
+	var chunks []string
+	step := size - overlap
+	for start := 0; start < len(words); start += step {
+		end := start + size
+		if end > len(words) {
+			end = len(words)
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			break
+		}
+	}
+	return chunks
+}
+
+// VectorChunk is one embedded piece of a record
+type VectorChunk struct {
+	Record int       `json:"record"`
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+// VectorIndex holds embeddings of record chunks for cosine similarity search
+type VectorIndex struct {
+	embedder Embedder
+	records  []Record
+	chunks   []VectorChunk
+}
+
+// EmbedRecords chunks and embeds the records. Chunk.Record indexes into records.
+func EmbedRecords(ctx context.Context, embedder Embedder, records []Record) ([]VectorChunk, error) {
+	var chunks []VectorChunk
+	var texts []string
+	for i, record := range records {
+		for _, text := range chunkText(RecordText(record), maxChunkWords, chunkOverlapWords) {
+			chunks = append(chunks, VectorChunk{Record: i, Text: text})
+			texts = append(texts, text)
+		}
+	}
+
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("error embedding records: %v", err)
+	}
+	for i := range chunks {
+		chunks[i].Vector = vectors[i]
+	}
+	return chunks, nil
+}
+
+// BuildVectorIndex embeds every record with the given embedder
+func BuildVectorIndex(ctx context.Context, embedder Embedder, records []Record) (*VectorIndex, error) {
+	chunks, err := EmbedRecords(ctx, embedder, records)
+	if err != nil {
+		return nil, err
+	}
+	return NewVectorIndex(embedder, records, chunks), nil
+}
+
+// NewVectorIndex creates an index from already embedded chunks
+func NewVectorIndex(embedder Embedder, records []Record, chunks []VectorChunk) *VectorIndex {
+	return &VectorIndex{embedder: embedder, records: records, chunks: chunks}
+}
+
+// Len returns the number of indexed records
+func (idx *VectorIndex) Len() int {
+	return len(idx.records)
+}
+
+// Search returns the k records most similar to the query, best first.
+// A record scores as its best matching chunk.
+func (idx *VectorIndex) Search(ctx context.Context, query string, k int) ([]ScoredRecord, error) {
+	vectors, err := idx.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("error embedding query: %v", err)
+	}
+
+	best := make(map[int]float64)
+	for _, chunk := range idx.chunks {
+		score := cosineSimilarity(vectors[0], chunk.Vector)
+		if score < minSimilarity {
+			continue
+		}
+		if current, ok := best[chunk.Record]; !ok || score > current {
+			best[chunk.Record] = score
+		}
+	}
+
+	results := make([]ScoredRecord, 0, len(best))
+	for i, score := range best {
+		results = append(results, ScoredRecord{Record: idx.records[i], Index: i, Score: score})
+	}
+
+	sortScored(results)
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// Corpus bundles a record set with the search indexes built over it
+type Corpus struct {
+	Records   []Record
+	Vectors   *VectorIndex
+	Store     RecordStore
+	Pipeline  *RetrievalPipeline
+	Resolver  *LocationResolver
+	Hierarchy *LocationHierarchy
+
+	// Version identifies the sources the corpus was loaded from, empty
+	// when unknown or when some failed to load
+	Version string
+}
+
+// NewCorpus builds all search indexes for the records
+func NewCorpus(ctx context.Context, records []Record, embedder Embedder) (*Corpus, error) {
+	vectors, err := BuildVectorIndex(ctx, embedder, records)
+	if err != nil {
+		return nil, err
+	}
+	return newCorpus(records, vectors, nil, nil), nil
+}
+
+// newCorpus completes a corpus around an existing vector index using the
+// default retrieval pipeline, over the given store or a new in-memory one
+// when nil. Document chunks are tagged on a copy of the records, so the
+// caller's slice is left as it was.
+func newCorpus(records []Record, vectors *VectorIndex, aliases map[string]string, store RecordStore) *Corpus {
+	resolver := NewLocationResolver(recordLocations(records), aliases)
+	records = TagDocuments(records, resolver)
+	if store == nil {
+		store = NewMemoryStore(records)
+	}
+
+	corpus := &Corpus{
+		Records:   records,
+		Vectors:   NewVectorIndex(vectors.embedder, records, vectors.chunks),
+		Store:     store,
+		Resolver:  resolver,
+		Hierarchy: NewLocationHierarchy(records),
+	}
+	// The default configuration only uses known retrievers and rerankers
+	corpus.Pipeline, _ = NewRetrievalPipeline(corpus, DefaultRetrievalConfig(), nil)
+	return corpus
+}
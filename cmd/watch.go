@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fileState is what the watcher compares between polls. Files are
+// compared by content, so an edit keeping the size within the resolution
+// of the modification time is still seen and a touch is not.
+type fileState struct {
+	size int64
+	hash string
+}
+
+// SourceWatcher polls the files behind the configured data sources and
+// reports the ones added, removed or modified since the previous poll
+type SourceWatcher struct {
+	resolve func() ([]string, error)
+	state   map[string]fileState
+	pending map[string]fileState
+	lastErr string
+}
+
+// NewSourceWatcher snapshots the files returned by resolve
+func NewSourceWatcher(resolve func() ([]string, error)) (*SourceWatcher, error) {
+	w := &SourceWatcher{resolve: resolve}
+	state, err := w.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.state = state
+	return w, nil
+}
+
+// snapshot stats and hashes every watched file. Missing files are left
+// out, so a deleted file shows up as a change.
+func (w *SourceWatcher) snapshot() (map[string]fileState, error) {
+	paths, err := w.resolve()
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			continue
+		}
+		state[path] = fileState{size: info.Size(), hash: hash}
+	}
+	return state, nil
+}
+
+// Poll returns the files that changed since the last reported change,
+// sorted. Changes are only reported once the files stayed the same for a
+// whole poll, so a file caught mid-write is not loaded. A resolve error
+// is returned once rather than on every poll.
+func (w *SourceWatcher) Poll() ([]string, error) {
+	state, err := w.snapshot()
+	if err != nil {
+		if err.Error() == w.lastErr {
+			return nil, nil
+		}
+		w.lastErr = err.Error()
+		return nil, err
+	}
+	w.lastErr = ""
+
+	changed := changedFiles(w.state, state)
+	if len(changed) == 0 || len(changedFiles(w.pending, state)) > 0 {
+		w.pending = state
+		return nil, nil
+	}
+	w.state = state
+	w.pending = nil
+	return changed, nil
+}
+
+// changedFiles lists the files added, removed or modified between two
+// snapshots, sorted
+func changedFiles(previous, current map[string]fileState) []string {
+	var changed []string
+	for path, state := range current {
+		if before, ok := previous[path]; !ok || before != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// WatchedPaths lists the data files and manifests behind the --data and
+// --manifest flags
+func WatchedPaths(paths []string, manifest string) ([]string, error) {
+	sources, err := ResolveSources(paths, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var watched []string
+	if manifest != "" {
+		watched = append(watched, manifest)
+	} else {
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				for _, name := range manifestNames {
+					watched = append(watched, filepath.Join(path, name))
+				}
+			}
+		}
+	}
+	for _, source := range sources {
+		watched = append(watched, source.Path)
+	}
+	return watched, nil
+}
+
+// CorpusReloader holds the corpus of an interactive session and swaps in
+// a freshly loaded one when the data changes. A failed load keeps the
+// previous corpus.
+type CorpusReloader struct {
+	// Install, when set, runs before a loaded corpus is swapped in, while
+	// queries are held off, to write state shared with the current corpus
+	// such as a persistent store. A failed install keeps the previous
+	// corpus.
+	Install func(corpus *Corpus) error
+
+	mu        sync.RWMutex
+	corpus    *Corpus
+	load      func(ctx context.Context) (*Corpus, error)
+	started   atomic.Uint64
+	installed uint64
+}
+
+// NewCorpusReloader starts from corpus and reloads with load
+func NewCorpusReloader(corpus *Corpus, load func(ctx context.Context) (*Corpus, error)) *CorpusReloader {
+	return &CorpusReloader{corpus: corpus, load: load}
+}
+
+// Use runs fn with the current corpus, holding off reloads until it returns
+func (r *CorpusReloader) Use(fn func(corpus *Corpus)) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn(r.corpus)
+}
+
+// Corpus returns the current corpus
+func (r *CorpusReloader) Corpus() *Corpus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.corpus
+}
+
+// Reload loads the data again and swaps in the new corpus. Queries keep
+// using the current corpus while the data loads and only wait for the
+// swap. A load finishing after one started later is discarded, so older
+// data never replaces newer data.
+func (r *CorpusReloader) Reload(ctx context.Context) error {
+	generation := r.started.Add(1)
+	corpus, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if generation < r.installed {
+		return nil
+	}
+	if r.Install != nil {
+		if err := r.Install(corpus); err != nil {
+			return err
+		}
+	}
+	r.corpus = corpus
+	r.installed = generation
+	return nil
+}
+
+// Watch polls the watcher every interval until ctx is done, reloading on
+// change and passing a notice for every reload to notify
+func (r *CorpusReloader) Watch(ctx context.Context, watcher *SourceWatcher, interval time.Duration, notify func(string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := watcher.Poll()
+		if err != nil {
+			notify(fmt.Sprintf("Reload failed, keeping previous data: %v", err))
+			continue
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		if err := r.Reload(ctx); err != nil {
+			notify(fmt.Sprintf("Reload failed, keeping previous data: %v", err))
+			continue
+		}
+		notify(fmt.Sprintf("Reloaded %d records after changes to %s", len(r.Corpus().Records), strings.Join(changed, ", ")))
+	}
+}
@@ -17,12 +17,12 @@ func TestOpenAIIntegration(t *testing.T) {
 		t.Skip("Skipping OpenAI integration test: OPENAI_API_KEY not set")
 	}
 
-	client := openai.NewClient(apiKey)
+	provider := cmd.NewOpenAIProvider(openai.NewClient(apiKey), "")
 	contextInfo := "According to tax_policies_2023.pdf, the tax rate in Travis County is 1.9%"
 	followUp := ""
 	question := "What's the tax rate in Travis County?"
 
-	answer, err := cmd.GenerateAnswer(client, contextInfo, followUp, question)
+	answer, err := cmd.GenerateAnswer(cmd.NewSession(provider), contextInfo, followUp, question)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, answer)
 	assert.Contains(t, answer, "1.9%")
@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeQuery(t *testing.T) {
+	t.Log("Testing query analysis...")
+
+	resolver := cmd.NewLocationResolver([]string{"California", "New York", "Travis County", "Travis Heights"}, nil)
+
+	analysis := cmd.AnalyzeQuery("Tell me about it", resolver)
+	assert.Empty(t, analysis.Terms, "Stop words should be removed")
+	assert.Empty(t, analysis.Locations, "Stop words must not match locations")
+	assert.True(t, analysis.IsFollowUp())
+
+	analysis = cmd.AnalyzeQuery("What are the hotel costs in New York?", resolver)
+	assert.Equal(t, []string{"hotel", "costs", "new", "york"}, analysis.Terms)
+	assert.Equal(t, []string{"cost"}, analysis.Topics)
+	if assert.Len(t, analysis.Locations, 1) {
+		assert.Equal(t, "New York", analysis.Locations[0].Location, "Multi-word names match as a phrase")
+	}
+
+	analysis = cmd.AnalyzeQuery("and the hotels?", resolver)
+	assert.True(t, analysis.IsFollowUp(), "Topic-only questions are follow-ups")
+
+	analysis = cmd.AnalyzeQuery("Dallas", resolver)
+	assert.False(t, analysis.IsFollowUp(), "Unknown content words are not follow-ups")
+	assert.Empty(t, analysis.Locations)
+	t.Log("✓ Successfully analyzed queries")
+}
+
+func TestBestLocationScoring(t *testing.T) {
+	t.Log("Testing location scoring...")
+
+	resolver := cmd.NewLocationResolver([]string{"Travis County", "Travis Heights", "Texas"}, nil)
+
+	analysis := cmd.AnalyzeQuery("county taxes near travis heights", resolver)
+	best, ok := analysis.BestLocation(resolver, "")
+	assert.True(t, ok)
+	assert.Equal(t, "Travis Heights", best.Location, "Full names beat partial and generic words")
+
+	analysis = cmd.AnalyzeQuery("travis", resolver)
+	assert.Len(t, analysis.Locations, 2, "Ambiguous words match every candidate")
+	best, _ = analysis.BestLocation(resolver, "Travis Heights")
+	assert.Equal(t, "Travis Heights", best.Location, "Ties prefer the current location")
+	best, _ = analysis.BestLocation(resolver, "")
+	assert.Equal(t, "Travis County", best.Location, "Remaining ties keep data order")
+
+	analysis = cmd.AnalyzeQuery("Travis vs Texas", resolver)
+	best, _ = analysis.BestLocation(resolver, "")
+	assert.Equal(t, "Texas", best.Location, "Exact names beat partial names regardless of position")
+	t.Log("✓ Successfully scored locations")
+}
+
+func TestFindRelevantInfoNarrowsToTopics(t *testing.T) {
+	t.Log("Testing answers narrowed to the topics asked about...")
+
+	corpus := newTestCorpus(t, testRecords("tourist", "tax", "cost"))
+	session := cmd.NewSession(nil)
+
+	result, _ := cmd.FindRelevantInfo(session, corpus, "Tell me about Texas")
+	assert.Contains(t, result, "The Alamo")
+	assert.Contains(t, result, "6.25%")
+	assert.Contains(t, result, "Travel Costs for Texas", "Questions without a topic should show everything")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "What's the tax rate in Texas?")
+	assert.Contains(t, result, "6.25%")
+	assert.NotContains(t, result, "The Alamo", "Tax questions should not show attractions")
+	assert.NotContains(t, result, "Travel Costs for Texas", "Tax questions should not show costs")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "and the hotels?")
+	assert.Contains(t, result, "Hotel: $150 per night", "Follow-ups should stay on the location")
+	assert.NotContains(t, result, "6.25%")
+
+	// Locations without data on the topic say so instead of showing the rest
+	corpus = newTestCorpus(t, testRecords("tourist"))
+	result, _ = cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, "What's the tax rate in Florida?")
+	assert.Contains(t, result, "No tax data for Florida.")
+	assert.NotContains(t, result, "Disney World", "Attractions do not answer tax questions")
+	t.Log("✓ Successfully narrowed answers to topics")
+}
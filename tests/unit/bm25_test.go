@@ -0,0 +1,52 @@
+package unit
+
+import (
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBM25SearchesAllFields(t *testing.T) {
+	t.Log("Testing BM25 lexical search across record values...")
+
+	index := cmd.NewBM25Index(testRecords("tourist", "tax"))
+
+	tests := []struct {
+		query            string
+		expectedLocation string
+		expectedType     string
+	}{
+		{query: "where is the Alamo?", expectedLocation: "Texas", expectedType: "tourist"},
+		{query: "Disneyland", expectedLocation: "California", expectedType: "tourist"},
+		{query: "6.25%", expectedLocation: "Texas", expectedType: "tax"},
+		{query: "Comptroller", expectedLocation: "Texas", expectedType: "tax"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			results := index.Search(tc.query, 3)
+			if assert.NotEmpty(t, results, "Expected at least one hit") {
+				assert.Equal(t, tc.expectedLocation, results[0].Record.Location)
+				assert.Equal(t, tc.expectedType, results[0].Record.DataType)
+				assert.Greater(t, results[0].Score, 0.0)
+			}
+			t.Logf("✓ Query %q ranked %s first", tc.query, tc.expectedLocation)
+		})
+	}
+
+	assert.Empty(t, index.Search("Dallas", 3), "Unknown terms should not match")
+}
+
+func TestBM25RanksRarerTermsHigher(t *testing.T) {
+	t.Log("Testing BM25 ranking order...")
+
+	index := cmd.NewBM25Index(testRecords("tourist"))
+	results := index.Search("tourism Miami", 0)
+	if assert.Len(t, results, 4, "Tourism matches every record") {
+		assert.Equal(t, "Florida", results[0].Record.Location)
+		assert.Greater(t, results[0].Score, results[1].Score)
+	}
+	t.Log("✓ Successfully ranked records")
+}
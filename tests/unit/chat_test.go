@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateAnswerSendsConversation(t *testing.T) {
+	t.Log("Testing multi-turn chat context...")
+
+	provider := cmd.NewFakeProvider("Texas has a 6.25% sales tax.", "Hotels in Texas average $150 per night.")
+	session := cmd.NewSession(provider)
+	corpus := newTestCorpus(t, testRecords("tourist", "cost"))
+	for _, question := range []string{"Tell me about Texas", "and the hotels there?"} {
+		info, followUp := cmd.FindRelevantInfo(session, corpus, question)
+		answer, err := cmd.GenerateAnswer(session, info, followUp, question)
+		assert.NoError(t, err)
+		session.RecordTurn(question, session.QueryLocation, info, answer)
+	}
+
+	if assert.Len(t, provider.Calls, 2) {
+		messages := provider.Calls[1]
+		roles := make([]string, len(messages))
+		for i, message := range messages {
+			roles[i] = message.Role
+		}
+		assert.Equal(t, []string{cmd.RoleSystem, cmd.RoleUser, cmd.RoleAssistant, cmd.RoleUser}, roles)
+		assert.Contains(t, messages[1].Content, "Question: Tell me about Texas")
+		assert.True(t, strings.HasPrefix(messages[2].Content, "Texas has a 6.25% sales tax."), "Earlier answers should be sent back")
+		assert.Contains(t, messages[3].Content, "Question: and the hotels there?")
+		assert.Contains(t, messages[3].Content, "Hotel: $150 per night", "The follow-up should retrieve the hotels of the last location")
+	}
+	t.Log("✓ Successfully sent the conversation")
+}
+
+func TestSessionMessagesTokenBudget(t *testing.T) {
+	t.Log("Testing history truncation and summaries...")
+
+	session := cmd.NewSession(nil)
+	session.HistoryTokens = 200
+	start := time.Now()
+	for i := range 10 {
+		session.Turns = append(session.Turns, cmd.Turn{
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Question: fmt.Sprintf("Question %d", i),
+			Location: "Texas",
+			Context:  strings.Repeat("retrieved ", 40),
+			Answer:   fmt.Sprintf("Answer %d", i),
+		})
+	}
+
+	messages := session.Messages("Current question", "Current information")
+	history := 0
+	for _, message := range messages[1 : len(messages)-1] {
+		history += (utf8.RuneCountInString(message.Content) + 3) / 4
+	}
+	assert.LessOrEqual(t, history, 200, "Earlier turns should fit the token budget")
+
+	last := messages[len(messages)-1]
+	assert.Equal(t, "Question: Current question\nInformation: Current information", last.Content)
+	assert.Equal(t, "Answer 9", messages[len(messages)-2].Content, "The latest turn should always be kept")
+	assert.Contains(t, messages[len(messages)-3].Content, "retrieved", "The latest turn should keep its information when it fits")
+
+	system := messages[0].Content
+	assert.Contains(t, system, "Earlier in this conversation the user asked:\n")
+	assert.Contains(t, system, "- Question 0 (about Texas)", "Older turns should be summarized")
+	assert.Less(t, len(messages), 2+2*10, "Not every turn should be sent in full")
+	t.Log("✓ Successfully kept the history within budget")
+}
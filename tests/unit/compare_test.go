@@ -0,0 +1,47 @@
+package unit
+
+import (
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistinctLocations(t *testing.T) {
+	t.Log("Testing detection of several locations in one query...")
+
+	resolver := cmd.NewLocationResolver([]string{"Florida", "New York", "Travis County", "Travis Heights"}, map[string]string{"NY": "New York"})
+
+	analysis := cmd.AnalyzeQuery("which is cheaper, Florida or New York (NY)?", resolver)
+	locations := analysis.DistinctLocations(resolver, "")
+	if assert.Len(t, locations, 2, "Aliases of the same location count once") {
+		assert.Equal(t, "Florida", locations[0].Location)
+		assert.Equal(t, "New York", locations[1].Location)
+	}
+
+	analysis = cmd.AnalyzeQuery("taxes in Travis", resolver)
+	assert.Len(t, analysis.DistinctLocations(resolver, ""), 1, "Ambiguous words are one location")
+	t.Log("✓ Successfully detected locations")
+}
+
+func TestFindRelevantInfoComparison(t *testing.T) {
+	t.Log("Testing side-by-side comparisons...")
+
+	result, followUp := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, testRecords()), "compare Florida and New York costs")
+	assert.Empty(t, followUp)
+	assert.Contains(t, result, "Comparison of Florida and New York:")
+	assert.Contains(t, result, "| Florida | 6.00% | $300 | $180 | $70 | November to April |")
+	assert.Contains(t, result, "| New York | 4.00% | $1,200 | n/a | n/a | April to June |")
+	assert.Contains(t, result, "- Florida: FL Tourism Department, Florida Department of Revenue, fl_expense_guide.pdf")
+	t.Log("✓ Successfully compared locations")
+}
+
+func TestFormatComparisonAmounts(t *testing.T) {
+	t.Log("Testing amounts in comparisons...")
+
+	result := cmd.FormatComparison([]string{"Texas", "New York"}, testRecords("cost"))
+	assert.Contains(t, result, "| Texas | n/a | $250 | $150 | $60 | n/a |", "Amounts with a dollar sign should keep one")
+	assert.Contains(t, result, "| New York | n/a | $1,200 | n/a | n/a | n/a |", "Values that are not amounts should not get a dollar sign")
+	t.Log("✓ Successfully formatted amounts")
+}
@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const travelNotes = `# Travel Notes
+
+## Texas
+Texas charges a 6.25% state sales tax.
+Travis County adds its own rate.
+
+## California
+Visit California in summer.
+`
+
+func TestLoadDocumentChunks(t *testing.T) {
+	t.Log("Testing Markdown document chunking...")
+
+	dir := filepath.Join("data", "documents")
+	defer os.RemoveAll(dir)
+
+	var long strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&long, "line %d has five words\n", i)
+	}
+	writeSourceFiles(t, dir, map[string]string{
+		"notes.md": travelNotes,
+		"long.txt": long.String(),
+	})
+
+	records, err := cmd.LoadData(filepath.Join(dir, "notes.md"), cmd.DataTypeDocument)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2, "Chunks should not cross headings") {
+		assert.Equal(t, "Travel Notes > Texas", records[0].Values["heading"])
+		assert.Equal(t, "Texas charges a 6.25% state sales tax. Travis County adds its own rate.", records[0].Values["text"])
+		assert.Equal(t, "notes.md", records[0].Source)
+		assert.Equal(t, &cmd.Provenance{
+			File:      filepath.Join(dir, "notes.md"),
+			Heading:   "Travel Notes > Texas",
+			StartLine: 4,
+			EndLine:   5,
+		}, records[0].Provenance)
+	}
+
+	records, err = cmd.LoadData(filepath.Join(dir, "long.txt"), cmd.DataTypeDocument)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3, "250 words should give three overlapping chunks") {
+		assert.Equal(t, 1, records[0].Provenance.StartLine)
+		assert.Equal(t, 24, records[0].Provenance.EndLine)
+		assert.Equal(t, 19, records[1].Provenance.StartLine, "Chunks should overlap")
+		assert.Equal(t, 50, records[2].Provenance.EndLine)
+	}
+	t.Log("✓ Successfully chunked documents")
+}
+
+func TestDocumentsTaggedAndQuoted(t *testing.T) {
+	t.Log("Testing document location tags and quotes...")
+
+	dir := filepath.Join("data", "documents")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{"notes.md": travelNotes})
+
+	documents, err := cmd.LoadData(filepath.Join(dir, "notes.md"), cmd.DataTypeDocument)
+	assert.NoError(t, err)
+	records := append(testRecords(), documents...)
+
+	resolver := cmd.NewLocationResolver([]string{"Texas", "Travis County", "California"}, nil)
+	tagged := cmd.TagDocuments(records, resolver)
+	assert.Empty(t, records[len(records)-2].Tags, "The input records should not be modified")
+	texasChunk := tagged[len(tagged)-2]
+	assert.Equal(t, "Texas", texasChunk.Location, "Heading locations should come first")
+	assert.Equal(t, []string{"Texas", "Travis County"}, texasChunk.Tags)
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, records), "What is the sales tax in Travis County?")
+	assert.Contains(t, result, "the county tax rate in Travis County (Texas) is 1.9%")
+	assert.Contains(t, result, "From notes.md (Travel Notes > Texas, lines 4-5):")
+	assert.Contains(t, result, "Travis County adds its own rate.")
+	t.Log("✓ Successfully quoted tagged documents")
+}
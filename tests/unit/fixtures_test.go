@@ -0,0 +1,57 @@
+package unit
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"goragagent/cmd"
+)
+
+// testRecords returns the data shared by the tests, or only the records of
+// the given data types: tourist information, tax rates and travel costs
+// for four states, plus county tax rates. Some costs are missing or not
+// numbers and King County's state has no data. Tests needing more, such as
+// conflicting sources or documents, append to it.
+func testRecords(dataTypes ...string) []cmd.Record {
+	records := []cmd.Record{
+		{Location: "California", DataType: "tourist", Values: map[string]string{"attractions": "Golden Gate Bridge and Disneyland", "best_time": "June to August"}, Source: "CA Tourism Board"},
+		{Location: "Texas", DataType: "tourist", Values: map[string]string{"attractions": "The Alamo and Space Center Houston", "best_time": "March to May"}, Source: "TX Tourism Bureau"},
+		{Location: "Florida", DataType: "tourist", Values: map[string]string{"attractions": "Disney World and Miami Beach", "best_time": "November to April"}, Source: "FL Tourism Department"},
+		{Location: "New York", DataType: "tourist", Values: map[string]string{"attractions": "Statue of Liberty and Times Square", "best_time": "April to June"}, Source: "NYC Tourism Office"},
+
+		{Location: "California", DataType: "tax", Values: map[string]string{"tax_rate": "7.25%"}, Source: "State Board of Equalization"},
+		{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": "6.25%"}, Source: "Texas Comptroller"},
+		{Location: "Florida", DataType: "tax", Values: map[string]string{"tax_rate": "6.00%"}, Source: "Florida Department of Revenue"},
+		{Location: "New York", DataType: "tax", Values: map[string]string{"tax_rate": "4.00%"}, Source: "NY Department of Taxation"},
+
+		{Location: "California", DataType: "cost", Values: map[string]string{"daily_cost": "350", "hotel_avg": "200"}, Source: "travel_budget_2023.pdf"},
+		{Location: "Texas", DataType: "cost", Values: map[string]string{"daily_cost": "$250", "hotel_avg": "150", "food_avg": "60"}, Source: "tx_cost_analysis.pdf"},
+		{Location: "Florida", DataType: "cost", Values: map[string]string{"daily_cost": "300", "hotel_avg": "180", "food_avg": "70"}, Source: "fl_expense_guide.pdf"},
+		{Location: "New York", DataType: "cost", Values: map[string]string{"daily_cost": "1,200", "hotel_avg": "n/a"}, Source: "ny_cost_report.csv"},
+
+		{Location: "Travis County", DataType: "county_tax", Values: map[string]string{"state": "Texas", "tax_rate": "1.9%"}, Source: "tax_policies_2023.pdf"},
+		{Location: "Harris County", DataType: "county_tax", Values: map[string]string{"state": "Texas", "tax_rate": "2.0%"}, Source: "houston_taxes.pdf"},
+		{Location: "King County", DataType: "county_tax", Values: map[string]string{"state": "Washington", "tax_rate": "2.2%"}, Source: "seattle_rates.csv"},
+	}
+	if len(dataTypes) == 0 {
+		return records
+	}
+	var selected []cmd.Record
+	for _, record := range records {
+		if slices.Contains(dataTypes, record.DataType) {
+			selected = append(selected, record)
+		}
+	}
+	return selected
+}
+
+// newTestCorpus indexes records with the offline embedder
+func newTestCorpus(t *testing.T, records []cmd.Record) *cmd.Corpus {
+	t.Helper()
+	corpus, err := cmd.NewCorpus(context.Background(), records, cmd.NewHashingEmbedder(0))
+	if err != nil {
+		t.Fatalf("Failed to build corpus: %v", err)
+	}
+	return corpus
+}
@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEffectiveDates(t *testing.T) {
+	t.Log("Testing effective date columns...")
+
+	dir := filepath.Join("data", "freshness")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,effective_date,valid_until,source\n" +
+			"Texas,6.25%,2024-01-01,2024-12-31,Texas Comptroller\n" +
+			"Ohio,5.75%,,,Ohio Department of Taxation\n",
+		"bad_date.csv":  "location,tax_rate,effective_date,source\nTexas,6.25%,last year,Texas Comptroller\n",
+		"bad_range.csv": "location,tax_rate,effective_date,valid_until,source\nTexas,6.25%,2024-01-01,2023-12-31,Texas Comptroller\n",
+	})
+
+	records, err := cmd.LoadData(filepath.Join(dir, "state_taxes.csv"), "tax")
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), records[0].EffectiveDate)
+		assert.Equal(t, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), records[0].ValidUntil)
+		assert.Equal(t, map[string]string{"tax_rate": "6.25%"}, records[0].Values, "Date columns should not be shown as values")
+		assert.True(t, records[1].EffectiveDate.IsZero(), "Dates should be optional")
+	}
+
+	_, err = cmd.LoadData(filepath.Join(dir, "bad_date.csv"), "tax")
+	assert.ErrorContains(t, err, `invalid data at row 2, column effective_date: expected a date such as 2024-01-31, got "last year"`)
+	_, err = cmd.LoadData(filepath.Join(dir, "bad_range.csv"), "tax")
+	assert.ErrorContains(t, err, "column valid_until: 2023-12-31 is before the effective date 2024-01-01")
+	t.Log("✓ Successfully loaded effective dates")
+}
+
+func TestMergePrefersCurrentRecords(t *testing.T) {
+	t.Log("Testing that the most recent valid record wins...")
+
+	date := func(year int) time.Time {
+		return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	records := []cmd.Record{
+		{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": "6.5%"}, Source: "Texas Comptroller", EffectiveDate: date(2025)},
+		{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": "6.25%"}, Source: "Texas Comptroller", EffectiveDate: date(2024)},
+		{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": "6%"}, Source: "Texas Comptroller", EffectiveDate: date(2023)},
+		{Location: "Ohio", DataType: "tax", Values: map[string]string{"tax_rate": "5.5%"}, Source: "Ohio Department of Taxation", ValidUntil: date(2023)},
+		{Location: "Ohio", DataType: "tax", Values: map[string]string{"tax_rate": "5.75%"}, Source: "Ohio Department of Taxation"},
+	}
+
+	for _, policy := range []string{cmd.MergeNewest, cmd.MergePriority, cmd.MergeShowAll} {
+		result, err := cmd.MergeRecords(records, cmd.MergeConfig{Policy: policy, AsOf: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)})
+		assert.NoError(t, err)
+		assert.Equal(t, []cmd.Record{records[1], records[4]}, result.Records,
+			"Future, superseded and expired records should be left out with the %s policy", policy)
+		assert.Empty(t, result.Conflicts, "Superseded values should not be reported as conflicts")
+	}
+	t.Log("✓ Successfully preferred the current records")
+}
+
+func TestAnswerStatesFreshness(t *testing.T) {
+	t.Log("Testing as-of dates and stale data warnings...")
+
+	records := []cmd.Record{
+		{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": "6.25%"}, Source: "Texas Comptroller",
+			EffectiveDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Location: "Texas", DataType: "cost", Values: map[string]string{"daily_cost": "200"}, Source: "Texas Tourism"},
+	}
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, records), "Tell me about Texas")
+	assert.Contains(t, result, "According to Texas Comptroller, the tax rate in Texas is 6.25% (as of 2020-01-01)\n"+
+		"  Warning: this data is from 2020-01-01, more than 365 days ago, and may be out of date.")
+	assert.Contains(t, result, "  - Food: not available")
+	assert.NotContains(t, result, "As of:", "Undated records should not get an as-of date")
+	t.Log("✓ Successfully stated how current the data is")
+}
+
+func TestExpiredRecordsLeftOutEverywhere(t *testing.T) {
+	t.Log("Testing that every answer uses the records in effect...")
+
+	// Expired rates come first, before the current ones they were replaced by
+	expired := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	records := append([]cmd.Record{
+		{Location: "California", DataType: "tax", Values: map[string]string{"tax_rate": "9.00%"}, Source: "Travel Guide 2019", ValidUntil: expired},
+		{Location: "Travis County", DataType: "county_tax", Values: map[string]string{"state": "Texas", "tax_rate": "3.00%"}, Source: "Travel Guide 2019", ValidUntil: expired},
+	}, testRecords("tax", "county_tax")...)
+	corpus := newTestCorpus(t, records)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "Lookup", query: "What is the tax rate in California?", expected: "the tax rate in California is 7.25%"},
+		{name: "Superlative", query: "Which state has the highest tax rate?", expected: "Highest tax rate: California (7.25%"},
+		{name: "Comparison", query: "Compare California and Texas", expected: "| California | 7.25% |"},
+		{name: "County_Rates", query: "Tell me about Texas", expected: "Travis County 1.90%"},
+		{name: "Combined_Rate", query: "Travis County taxes", expected: "Combined tax rate in Travis County: 8.15% (Texas 6.25% + Travis County 1.90%)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, tt.query)
+			assert.Contains(t, result, tt.expected)
+			assert.NotContains(t, result, "9.00%", "Expired rates should not be used")
+			assert.NotContains(t, result, "3.00%", "Expired rates should not be used")
+			t.Logf("✓ Answered %q with the current rate", tt.query)
+		})
+	}
+}
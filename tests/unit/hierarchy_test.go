@@ -0,0 +1,86 @@
+package unit
+
+import (
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationHierarchy(t *testing.T) {
+	t.Log("Testing county to state hierarchy...")
+
+	records := testRecords()
+	hierarchy := cmd.NewLocationHierarchy(records)
+
+	state, ok := hierarchy.Parent("Travis County")
+	assert.True(t, ok)
+	assert.Equal(t, "Texas", state)
+	assert.Equal(t, []string{"Harris County", "Travis County"}, hierarchy.Children("Texas"))
+	assert.False(t, hierarchy.IsChild("Texas"))
+
+	combined, ok := cmd.CombinedTaxRate(records, hierarchy, "Travis County")
+	assert.True(t, ok)
+	assert.Equal(t, "Combined tax rate in Travis County: 8.15% (Texas 6.25% + Travis County 1.90%)", combined)
+
+	_, ok = cmd.CombinedTaxRate(records, hierarchy, "King County")
+	assert.False(t, ok, "No combined rate without state data")
+	t.Log("✓ Successfully linked counties to states")
+}
+
+func TestFindRelevantInfoCountySurfacesState(t *testing.T) {
+	t.Log("Testing county questions surface state data...")
+
+	corpus := newTestCorpus(t, testRecords())
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, "What's the tax rate in Travis County?")
+	assert.Contains(t, result, "the county tax rate in Travis County (Texas) is 1.9%")
+	assert.Contains(t, result, "the tax rate in Texas is 6.25%")
+	assert.NotContains(t, result, "Travel Costs for Texas", "Tax questions should only show the state's taxes")
+	assert.Contains(t, result, "Combined tax rate in Travis County: 8.15%")
+
+	result, _ = cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, "Tell me about Travis County")
+	assert.Contains(t, result, "Travel Costs for Texas")
+	assert.Contains(t, result, "Combined tax rate in Travis County: 8.15%")
+	t.Log("✓ Successfully surfaced state data")
+}
+
+func TestPlanScopesStatesAndCounties(t *testing.T) {
+	records := testRecords()
+
+	plan, ok := cmd.PlanQuery("which county has the highest tax rate?")
+	assert.True(t, ok)
+	result, err := plan.Execute(records)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Highest tax rate: King County")
+	assert.NotContains(t, result, "Texas:")
+
+	plan, _ = cmd.PlanQuery("which state has the lowest tax rate?")
+	result, err = plan.Execute(records)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Lowest tax rate: New York")
+	assert.NotContains(t, result, "County")
+}
+
+func TestUnscopedTaxRatesCompareStates(t *testing.T) {
+	t.Log("Testing tax rate questions naming no scope...")
+
+	records := testRecords()
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: "which place has the lowest tax rate?", expected: "Lowest tax rate: New York"},
+		{query: "average tax rate", expected: "5.88%"},
+	}
+	for _, tt := range tests {
+		plan, ok := cmd.PlanQuery(tt.query)
+		assert.True(t, ok)
+		result, err := plan.Execute(records)
+		assert.NoError(t, err)
+		assert.Contains(t, result, tt.expected)
+		assert.NotContains(t, result, "County", "County add-on rates should not be compared with state rates")
+		t.Logf("✓ Answered %q over the states", tt.query)
+	}
+	t.Log("✓ Successfully compared state tax rates")
+}
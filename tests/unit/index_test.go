@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexReusesUnchangedSources(t *testing.T) {
+	t.Log("Testing incremental vector index updates...")
+
+	err := os.MkdirAll("data/test", 0755)
+	if err != nil {
+		t.Fatalf("Failed to create test data directory: %v", err)
+	}
+	defer os.RemoveAll("data/test")
+
+	taxFile := filepath.Join("data", "test", "taxes.csv")
+	costFile := filepath.Join("data", "test", "costs.csv")
+	assert.NoError(t, os.WriteFile(taxFile, []byte("location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n"), 0644))
+	assert.NoError(t, os.WriteFile(costFile, []byte("location,daily_cost,source\nTexas,250,tx_cost_analysis.pdf\n"), 0644))
+
+	sources := []cmd.DataSource{
+		{Path: taxFile, DataType: "tax"},
+		{Path: costFile, DataType: "cost"},
+	}
+	embedder := cmd.NewHashingEmbedder(0)
+	ctx := context.Background()
+
+	index, update, err := cmd.UpdateIndex(ctx, nil, sources, embedder)
+	assert.NoError(t, err)
+	assert.Len(t, update.Embedded, 2, "First build should embed every source")
+
+	indexFile := filepath.Join("data", "test", "index.json")
+	assert.NoError(t, cmd.SaveIndex(indexFile, index))
+	loaded, err := cmd.LoadIndex(indexFile)
+	assert.NoError(t, err)
+
+	_, update, err = cmd.UpdateIndex(ctx, loaded, sources, embedder)
+	assert.NoError(t, err)
+	assert.Empty(t, update.Embedded, "Unchanged sources should not be re-embedded")
+	assert.Len(t, update.Reused, 2)
+
+	assert.NoError(t, os.WriteFile(costFile, []byte("location,daily_cost,source\nTexas,275,tx_cost_analysis.pdf\n"), 0644))
+	index, update, err = cmd.UpdateIndex(ctx, loaded, sources, embedder)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{costFile}, update.Embedded, "Only the changed source should be re-embedded")
+
+	corpus := index.Corpus(embedder, nil)
+	assert.Len(t, corpus.Records, 2)
+	results, err := corpus.Vectors.Search(ctx, "Texas Comptroller", 1)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "tax", results[0].Record.DataType)
+	}
+	t.Log("✓ Successfully reused unchanged sources")
+}
+
+func TestLoadIndexMissingFile(t *testing.T) {
+	index, err := cmd.LoadIndex("data/test/missing.idx.json")
+	assert.NoError(t, err, "A missing index is not an error")
+	assert.Nil(t, index)
+}
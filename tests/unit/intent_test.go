@@ -0,0 +1,196 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleClassifier(t *testing.T) {
+	t.Log("Running rule-based intent classification tests...")
+
+	resolver := cmd.NewLocationResolver([]string{"California", "Texas", "Florida"}, nil)
+	tests := []struct {
+		query    string
+		expected cmd.Intent
+	}{
+		{"Which locations did I ask about?", cmd.IntentHistory},
+		{"Show my previous questions", cmd.IntentHistory},
+		{"Which locations have beaches?", cmd.IntentLookup},
+		{"What's the tax rate in Texas?", cmd.IntentLookup},
+		{"Compare California and Texas", cmd.IntentCompare},
+		{"Which state has the lowest tax rate?", cmd.IntentAggregate},
+		{"Somewhere warm in winter?", cmd.IntentLookup},
+		{"Plan a 5 day trip to Florida", cmd.IntentPlan},
+		{"What is the cost a day in Texas?", cmd.IntentLookup},
+		{"How much is a hotel in Texas for 3 nights?", cmd.IntentLookup},
+		{"Thanks!", cmd.IntentSmalltalk},
+		{"Hello there", cmd.IntentSmalltalk},
+		{"Tell me a joke", cmd.IntentOutOfScope},
+		{"What's the weather in Texas?", cmd.IntentOutOfScope},
+		{"Tell me a joke about Texas", cmd.IntentOutOfScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			analysis := cmd.AnalyzeQuery(tt.query, resolver)
+			var locations []string
+			for _, match := range analysis.DistinctLocations(resolver, "") {
+				locations = append(locations, match.Location)
+			}
+			intent, err := cmd.RuleClassifier{}.Classify(context.Background(), cmd.IntentRequest{
+				Query:     tt.query,
+				Search:    tt.query,
+				Analysis:  analysis,
+				Locations: locations,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, intent)
+			t.Logf("✓ Classified %q as %s", tt.query, intent)
+		})
+	}
+}
+
+func TestLLMClassifier(t *testing.T) {
+	t.Log("Testing LLM intent classification...")
+
+	provider := cmd.NewFakeProvider("Intent: out-of-scope", "I am not sure")
+	classifier, err := cmd.NewIntentClassifier(cmd.ClassifierLLM, provider)
+	assert.NoError(t, err)
+
+	intent, err := classifier.Classify(context.Background(), cmd.IntentRequest{Query: "Who won the game?", Search: "Who won the game?"})
+	assert.NoError(t, err)
+	assert.Equal(t, cmd.IntentOutOfScope, intent)
+
+	_, err = classifier.Classify(context.Background(), cmd.IntentRequest{Query: "Hmm", Search: "Hmm"})
+	assert.ErrorContains(t, err, "unknown intent")
+
+	_, err = cmd.NewIntentClassifier(cmd.ClassifierLLM, nil)
+	assert.ErrorContains(t, err, "requires an LLM provider")
+	_, err = cmd.NewIntentClassifier("magic", nil)
+	assert.ErrorContains(t, err, `unknown intent classifier "magic"`)
+	t.Log("✓ Successfully classified with the LLM")
+}
+
+func TestFindRelevantInfoDispatchesOnIntent(t *testing.T) {
+	t.Log("Testing answers routed by intent...")
+
+	corpus := newTestCorpus(t, testRecords("tourist", "cost"))
+	session := cmd.NewSession(nil)
+	cmd.FindRelevantInfo(session, corpus, "Tell me about Texas")
+
+	result, _ := cmd.FindRelevantInfo(session, corpus, "Which locations have beaches?")
+	assert.NotContains(t, result, "Recent locations you've asked about", "A data question should not be answered from memory")
+	assert.Contains(t, result, "Miami Beach")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Which locations did I ask about?")
+	assert.Contains(t, result, "Recent locations you've asked about")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Thank you!")
+	assert.Contains(t, result, "You're welcome!")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Tell me a joke")
+	assert.Contains(t, result, "I can only answer questions about tax rates, tourist attractions and travel costs.")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Plan a 5 day trip to Florida")
+	assert.Contains(t, result, "Disney World and Miami Beach")
+	assert.Contains(t, result, "Estimated cost of a 5-day trip to Florida: $1500 ($300 per day)")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Plan a 3-night trip to Texas")
+	assert.Contains(t, result, "Estimated hotel cost of a 3-night trip to Texas: $450 ($150 per night)", "Nights should be priced by the hotel cost")
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Plan a trip to Texas at $250 a day")
+	assert.NotContains(t, result, "Estimated", "A rate should not be read as the length of the trip")
+
+	for _, query := range []string{"What is the cost a day in Texas?", "How much is a hotel in Texas for 3 nights?"} {
+		result, _ = cmd.FindRelevantInfo(session, corpus, query)
+		assert.Contains(t, result, "Hotel: $150 per night")
+		assert.NotContains(t, result, "Estimated", "%q does not plan a trip", query)
+	}
+
+	result, _ = cmd.FindRelevantInfo(session, corpus, "Tell me about Texas")
+	assert.Contains(t, result, "The Alamo", "Lookups naming no topic should still show attractions")
+	assert.Contains(t, result, "Hotel: $150 per night")
+	t.Log("✓ Successfully routed questions by intent")
+}
+
+func TestFindRelevantInfoFollowsClassifier(t *testing.T) {
+	t.Log("Testing answers routed by the LLM classifier...")
+
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	query := "California and Texas"
+
+	session := cmd.NewSession(nil)
+	session.Classifier = &cmd.LLMClassifier{Provider: cmd.NewFakeProvider("compare")}
+	result, _ := cmd.FindRelevantInfo(session, corpus, query)
+	assert.Contains(t, result, "Comparison of California and Texas")
+
+	session = cmd.NewSession(nil)
+	session.Classifier = &cmd.LLMClassifier{Provider: cmd.NewFakeProvider("lookup")}
+	result, _ = cmd.FindRelevantInfo(session, corpus, query)
+	assert.NotContains(t, result, "Comparison of", "A lookup should not compare")
+	assert.Contains(t, result, "Golden Gate Bridge")
+	assert.NotContains(t, result, "The Alamo", "A lookup should show one location")
+
+	session = cmd.NewSession(nil)
+	session.Classifier = &cmd.LLMClassifier{Provider: cmd.NewFakeProvider("out-of-scope")}
+	result, _ = cmd.FindRelevantInfo(session, corpus, query)
+	assert.Contains(t, result, "I can only answer questions about tax rates, tourist attractions and travel costs.")
+	t.Log("✓ Successfully followed the classifier")
+}
+
+func TestRecommendationsRankEveryLocation(t *testing.T) {
+	t.Log("Testing questions asking where to go...")
+
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+		excluded string
+		best     string
+	}{
+		{name: "Season", query: "I want somewhere warm in winter", expected: "best first: Florida\n", excluded: "Texas", best: "Florida"},
+		{name: "Summer", query: "Where should I go in summer?", expected: "best first: California, New York\n", excluded: "Texas", best: "California"},
+		{name: "Month", query: "Anywhere nice to visit in April?", expected: "best first: Texas, Florida, New York\n", excluded: "California", best: "Texas"},
+		{name: "Retrieval", query: "Can you recommend somewhere with a beach?", expected: "best first: Florida\n", excluded: "Texas", best: "Florida"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := cmd.NewSession(nil)
+			cmd.FindRelevantInfo(session, corpus, "Tell me about Texas")
+			result, _ := cmd.FindRelevantInfo(session, corpus, tt.query)
+			assert.Contains(t, result, tt.expected)
+			assert.NotContains(t, result, tt.excluded)
+			assert.Equal(t, tt.best, session.LastLocation, "The best recommendation should become the current location")
+			t.Logf("✓ Recommended %q", tt.query)
+		})
+	}
+
+	session := cmd.NewSession(nil)
+	result, _ := cmd.FindRelevantInfo(session, corpus, "somewhere to ski in the mountains")
+	assert.Contains(t, result, "No relevant information found", "Nothing should be recommended without a match")
+	t.Log("✓ Successfully recommended locations")
+}
+
+func TestFailedClassificationFallsBackToRules(t *testing.T) {
+	t.Log("Testing fallback to the rules...")
+
+	provider := cmd.NewFakeProvider()
+	provider.Err = errors.New("service unavailable")
+	var log bytes.Buffer
+	session := cmd.NewSession(nil)
+	session.Classifier = &cmd.LLMClassifier{Provider: provider}
+	session.Log = &log
+
+	result, _ := cmd.FindRelevantInfo(session, newTestCorpus(t, testRecords("tourist")), "Hello")
+	assert.Contains(t, result, "Hello!")
+	assert.Contains(t, log.String(), "intent classification with llm failed: service unavailable")
+	assert.Contains(t, log.String(), `classified "Hello" as smalltalk (rules)`)
+	t.Log("✓ Successfully fell back to the rules")
+}
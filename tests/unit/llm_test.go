@@ -1,6 +1,9 @@
 package unit
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
 
 	"goragagent/cmd"
@@ -18,7 +21,7 @@ func TestGenerateAnswerWithNilClient(t *testing.T) {
 	t.Logf("Question: %s", question)
 
 	// Test with nil client
-	answer, err := cmd.GenerateAnswer(nil, contextInfo, "", question)
+	answer, err := cmd.GenerateAnswer(cmd.NewSession(nil), contextInfo, "", question)
 	assert.NoError(t, err, "Should not error with nil client")
 	assert.Equal(t, contextInfo, answer, "Should return context as answer in fallback mode")
 	t.Log("✓ Successfully handled nil client case")
@@ -34,8 +37,90 @@ func TestGenerateAnswerBasicResponse(t *testing.T) {
 	t.Logf("Question: %s", question)
 
 	// Test without OpenAI client (basic response mode)
-	answer, err := cmd.GenerateAnswer(nil, contextInfo, "", question)
+	answer, err := cmd.GenerateAnswer(cmd.NewSession(nil), contextInfo, "", question)
 	assert.NoError(t, err, "Should not error in basic response mode")
 	assert.Equal(t, contextInfo, answer, "Should return context as answer in basic mode")
 	t.Log("✓ Successfully generated basic response")
 }
+
+func TestGenerateAnswerWithFakeProvider(t *testing.T) {
+	t.Log("Testing LLM response generation with a deterministic fake provider...")
+
+	provider := cmd.NewFakeProvider("The tax rate in Travis County is 1.9%.")
+	contextInfo := "According to tax_policies_2023.pdf, the tax rate in Travis County is 1.9%"
+	question := "What's the tax rate in Travis County?"
+
+	answer, err := cmd.GenerateAnswer(cmd.NewSession(provider), contextInfo, "\nFollow up?", question)
+	assert.NoError(t, err, "Should not error with fake provider")
+	assert.Equal(t, "The tax rate in Travis County is 1.9%.\nFollow up?", answer)
+	assert.Len(t, provider.Calls, 1, "Provider should be called once")
+	if assert.NotEmpty(t, provider.Calls[0]) {
+		question := provider.Calls[0][len(provider.Calls[0])-1]
+		assert.Equal(t, cmd.RoleUser, question.Role)
+		assert.Contains(t, question.Content, contextInfo, "Prompt should include context")
+	}
+	t.Log("✓ Successfully generated answer with fake provider")
+}
+
+func TestFakeProviderConcurrentCalls(t *testing.T) {
+	t.Log("Testing the fake provider from several goroutines...")
+
+	provider := cmd.NewFakeProvider("first", "second")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := provider.ChatCompletion(context.Background(), []cmd.ChatMessage{{Role: cmd.RoleUser, Content: "Tell me about Texas"}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, provider.Calls, 10, "Every call should be recorded")
+	t.Log("✓ Successfully recorded concurrent calls")
+}
+
+func TestGenerateAnswerProviderErrorFallsBack(t *testing.T) {
+	t.Log("Testing fallback to context when the provider fails...")
+
+	provider := cmd.NewFakeProvider()
+	provider.Err = errors.New("rate limited")
+
+	answer, err := cmd.GenerateAnswer(cmd.NewSession(provider), "Test context", "", "Test question")
+	assert.NoError(t, err, "Provider errors should fall back to context")
+	assert.Equal(t, "Test context", answer)
+	t.Log("✓ Successfully fell back to context")
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Log("Testing provider selection from config...")
+
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("GORAGAGENT_PROVIDER", "")
+	t.Setenv("GORAGAGENT_BASE_URL", "")
+
+	provider, err := cmd.NewProvider(cmd.ProviderConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, provider, "No config should mean basic mode")
+
+	provider, err = cmd.NewProvider(cmd.ProviderConfig{Provider: cmd.ProviderFake})
+	assert.NoError(t, err)
+	assert.Equal(t, "fake", provider.ModelName())
+
+	provider, err = cmd.NewProvider(cmd.ProviderConfig{BaseURL: "http://localhost:8080/v1", Model: "llama3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "llama3", provider.ModelName())
+
+	provider, err = cmd.NewProvider(cmd.ProviderConfig{Provider: cmd.ProviderOpenAICompatible})
+	assert.Error(t, err, "Compatible provider requires a base URL")
+	assert.True(t, provider == nil, "A failed provider should be a nil interface")
+
+	provider, err = cmd.NewProvider(cmd.ProviderConfig{Provider: cmd.ProviderOpenAICompatible, BaseURL: "http://localhost:8080/v1"})
+	assert.Error(t, err, "Compatible provider requires a model")
+	assert.True(t, provider == nil, "A failed provider should be a nil interface")
+
+	provider, err = cmd.NewProvider(cmd.ProviderConfig{Provider: "unknown"})
+	assert.Error(t, err)
+	assert.True(t, provider == nil, "An unknown provider should be a nil interface")
+	t.Log("✓ Successfully selected providers")
+}
@@ -0,0 +1,170 @@
+package unit
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadStructuredFormats(t *testing.T) {
+	t.Log("Running JSON, JSONL and YAML loader tests...")
+
+	dir := filepath.Join("data", "loaders")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"costs.json":  `[{"location": "Texas", "daily_cost": 250, "hotel_avg": "150", "source": "tx_cost_analysis.pdf"}]`,
+		"export.json": `{"records": [{"location": "Texas", "daily_cost": 250, "hotel_avg": 150, "source": "tx_cost_analysis.pdf"}]}`,
+		"costs.jsonl": "{\"location\": \"Texas\", \"daily_cost\": 250, \"hotel_avg\": 150, \"source\": \"tx_cost_analysis.pdf\"}\n\n",
+		"costs.yaml":  "- location: Texas\n  daily_cost: 250\n  hotel_avg: 150\n  source: tx_cost_analysis.pdf\n",
+	})
+
+	expected := cmd.Record{
+		Location: "Texas",
+		DataType: "cost",
+		Values:   map[string]string{"daily_cost": "250", "hotel_avg": "150"},
+		Source:   "tx_cost_analysis.pdf",
+		Numbers: map[string]cmd.NumericValue{
+			"daily_cost": {Value: 250, Unit: cmd.UnitCurrency},
+			"hotel_avg":  {Value: 150, Unit: cmd.UnitCurrency},
+		},
+	}
+
+	for _, name := range []string{"costs.json", "export.json", "costs.jsonl", "costs.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			records, err := cmd.LoadData(path, "cost")
+			assert.NoError(t, err)
+
+			info, err := os.Stat(path)
+			assert.NoError(t, err)
+			expected := expected
+			expected.Modified = info.ModTime()
+			assert.Equal(t, []cmd.Record{expected}, records, "Records should carry their file's modification time")
+			t.Logf("✓ Successfully loaded %s", name)
+		})
+	}
+}
+
+func TestLoadTrimsFields(t *testing.T) {
+	t.Log("Testing that padded fields are trimmed at load...")
+
+	dir := filepath.Join("data", "loaders")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"rates.csv":  "location,tax_rate,source\n Florida ,  6.00% ,Florida Department of Revenue \n",
+		"rates.json": `[{"location": " Florida", "tax_rate": "6.00% ", "source": "Florida Department of Revenue "}]`,
+	})
+
+	for _, name := range []string{"rates.csv", "rates.json"} {
+		t.Run(name, func(t *testing.T) {
+			records, err := cmd.LoadData(filepath.Join(dir, name), "tax")
+			assert.NoError(t, err)
+			if assert.Len(t, records, 1) {
+				assert.Equal(t, "Florida", records[0].Location)
+				assert.Equal(t, "Florida Department of Revenue", records[0].Source)
+				assert.Equal(t, map[string]string{"tax_rate": "6.00%"}, records[0].Values)
+			}
+
+			result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, records), "What is the tax rate in Florida?")
+			assert.Contains(t, result, "According to Florida Department of Revenue, the tax rate in Florida is 6.00%")
+			t.Logf("✓ Successfully trimmed %s", name)
+		})
+	}
+}
+
+func TestLoadStructuredFormatErrors(t *testing.T) {
+	t.Log("Running structured loader validation tests...")
+
+	dir := filepath.Join("data", "loaders")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		content  string
+		errorMsg string
+	}{
+		{
+			name:     "injection.json",
+			content:  `[{"location": "$(rm -rf /)", "tax_rate": "1.0%", "source": "malicious.txt"}]`,
+			errorMsg: "invalid data at row 1: invalid character in field",
+		},
+		{
+			name:     "traversal.jsonl",
+			content:  "{\"location\": \"Texas\", \"tax_rate\": \"6.25%\", \"source\": \"ok\"}\n{\"location\": \"../../etc/passwd\", \"tax_rate\": \"1%\", \"source\": \"x\"}\n",
+			errorMsg: "invalid data at row 2: invalid character in path",
+		},
+		{
+			name:     "nested.yaml",
+			content:  "records:\n  - location: Texas\n    tax_rate: {state: 6.25%}\n    source: Texas Comptroller\n",
+			errorMsg: "invalid data at row 1, column tax_rate: nested values are not supported",
+		},
+		{
+			name:     "malformed.jsonl",
+			content:  "{\"location\": \"Texas\"\n",
+			errorMsg: "invalid data at row 1:",
+		},
+		{
+			name:     "empty.json",
+			content:  `[]`,
+			errorMsg: "data file is empty or missing records",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			writeSourceFiles(t, dir, map[string]string{tc.name: tc.content})
+			_, err := cmd.LoadData(filepath.Join(dir, tc.name), "tax")
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.errorMsg)
+				t.Logf("✓ Successfully detected invalid input: %v", err)
+			}
+		})
+	}
+
+	_, err := cmd.LoadData(filepath.Join(dir, "rates.xml"), "tax")
+	assert.ErrorContains(t, err, "invalid file extension: must be .csv, .json, .jsonl")
+}
+
+func TestRegisterLoader(t *testing.T) {
+	t.Log("Testing custom loader registration...")
+
+	dir := filepath.Join("data", "loaders")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"rates.tsv": "location\ttax_rate\tsource\nTexas\t6.25%\tTexas Comptroller\n",
+	})
+
+	cmd.RegisterLoader(".tsv", func(r io.Reader, source cmd.DataSource, schema *cmd.Schema) ([]cmd.Record, error) {
+		reader := csv.NewReader(r)
+		reader.Comma = '\t'
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		var records []cmd.Record
+		for _, row := range rows[1:] {
+			records = append(records, cmd.Record{
+				Location: row[0],
+				DataType: source.DataType,
+				Values:   map[string]string{rows[0][1]: row[1]},
+				Source:   row[2],
+			})
+		}
+		return records, nil
+	})
+
+	sources, err := cmd.ResolveSources([]string{dir}, "")
+	assert.NoError(t, err)
+	if assert.Len(t, sources, 1, "Registered extensions should be picked up from directories") {
+		records, err := cmd.LoadSource(sources[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "Texas", records[0].Location)
+	}
+	t.Log("✓ Successfully used a registered loader")
+}
@@ -0,0 +1,176 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// conflictingRecords extends the shared tax rates with a duplicate of the
+// California rate, a source disagreeing with it and one agreeing with Texas
+func conflictingRecords() []cmd.Record {
+	records := testRecords("tax")
+	return append(records, records[0],
+		cmd.Record{Location: "California", DataType: "tax", Values: map[string]string{"tax_rate": "7.5%"}, Source: "Travel Guide 2019"},
+		cmd.Record{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": "6.250%"}, Source: "Travel Guide 2019"},
+	)
+}
+
+func TestMergeRecords(t *testing.T) {
+	t.Log("Running record merge tests...")
+
+	records := conflictingRecords()
+	tests := []struct {
+		name     string
+		config   cmd.MergeConfig
+		expected []cmd.Record
+		chosen   string
+	}{
+		{
+			name:     "Newest",
+			config:   cmd.MergeConfig{Policy: cmd.MergeNewest},
+			expected: []cmd.Record{records[2], records[3], records[5], records[6]},
+			chosen:   "Travel Guide 2019",
+		},
+		{
+			name:     "Source_Priority",
+			config:   cmd.MergeConfig{Policy: cmd.MergePriority, Priority: []string{"texas comptroller", "State Board of Equalization"}},
+			expected: []cmd.Record{records[0], records[1], records[2], records[3]},
+			chosen:   "State Board of Equalization",
+		},
+		{
+			name:     "Show_All",
+			config:   cmd.MergeConfig{Policy: cmd.MergeShowAll},
+			expected: []cmd.Record{records[0], records[1], records[2], records[3], records[5], records[6]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := cmd.MergeRecords(records, tt.config)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Records)
+			assert.Equal(t, []cmd.Conflict{{
+				Location: "California",
+				DataType: "tax",
+				Field:    "tax_rate",
+				Values: []cmd.SourcedValue{
+					{Value: "7.25%", Source: "State Board of Equalization"},
+					{Value: "7.5%", Source: "Travel Guide 2019"},
+				},
+				Chosen: tt.chosen,
+				Policy: tt.config.Policy,
+			}}, result.Conflicts, "Only disagreeing values should be conflicts")
+			t.Logf("✓ Successfully merged with the %s policy", tt.config.Policy)
+		})
+	}
+
+	_, err := cmd.MergeRecords(records, cmd.MergeConfig{Policy: "oldest"})
+	assert.ErrorContains(t, err, `unknown merge policy "oldest"`)
+}
+
+func TestMergeRecordsFieldByField(t *testing.T) {
+	t.Log("Running field by field merge tests...")
+
+	// Both sources give California's daily cost; only the first gives the
+	// hotel average and only the second the food average
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(0, 1, 0)
+	budget := cmd.Record{Location: "California", DataType: "cost", Values: map[string]string{"daily_cost": "350", "hotel_avg": "200"}, Source: "travel_budget_2023.pdf"}
+	guide := cmd.Record{Location: "California", DataType: "cost", Values: map[string]string{"daily_cost": "400", "food_avg": "80"}, Source: "Travel Guide 2019"}
+	only := func(record cmd.Record, field string) cmd.Record {
+		record.Values = map[string]string{field: record.Values[field]}
+		return record
+	}
+
+	tests := []struct {
+		name     string
+		config   cmd.MergeConfig
+		budget   time.Time
+		guide    time.Time
+		expected func(budget, guide cmd.Record) []cmd.Record
+		chosen   string
+	}{
+		{
+			name:   "Newest_Loaded_Last",
+			config: cmd.MergeConfig{Policy: cmd.MergeNewest},
+			budget: older, guide: newer,
+			expected: func(budget, guide cmd.Record) []cmd.Record { return []cmd.Record{only(budget, "hotel_avg"), guide} },
+			chosen:   "Travel Guide 2019",
+		},
+		{
+			name:   "Newest_Loaded_First",
+			config: cmd.MergeConfig{Policy: cmd.MergeNewest},
+			budget: newer, guide: older,
+			expected: func(budget, guide cmd.Record) []cmd.Record { return []cmd.Record{budget, only(guide, "food_avg")} },
+			chosen:   "travel_budget_2023.pdf",
+		},
+		{
+			name:   "Source_Priority",
+			config: cmd.MergeConfig{Policy: cmd.MergePriority, Priority: []string{"travel_budget_2023.pdf"}},
+			budget: older, guide: newer,
+			expected: func(budget, guide cmd.Record) []cmd.Record { return []cmd.Record{budget, only(guide, "food_avg")} },
+			chosen:   "travel_budget_2023.pdf",
+		},
+		{
+			name:   "Show_All",
+			config: cmd.MergeConfig{Policy: cmd.MergeShowAll},
+			budget: older, guide: newer,
+			expected: func(budget, guide cmd.Record) []cmd.Record { return []cmd.Record{budget, guide} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget, guide := budget, guide
+			budget.Modified, guide.Modified = tt.budget, tt.guide
+
+			result, err := cmd.MergeRecords([]cmd.Record{budget, guide}, tt.config)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected(budget, guide), result.Records, "Fields only one source gives should be kept")
+			if assert.Len(t, result.Conflicts, 1) {
+				assert.Equal(t, "daily_cost", result.Conflicts[0].Field)
+				assert.Equal(t, tt.chosen, result.Conflicts[0].Chosen)
+			}
+			t.Logf("✓ Successfully merged fields with the %s policy", tt.config.Policy)
+		})
+	}
+}
+
+func TestConflictsInAnswer(t *testing.T) {
+	t.Log("Testing conflicts surfaced in answers...")
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, conflictingRecords()), "What is the tax rate in California?")
+	assert.Contains(t, result, "According to Travel Guide 2019, the tax rate in California is 7.5%")
+	assert.NotContains(t, result, "According to State Board of Equalization")
+	assert.Contains(t, result, "Note: sources disagree on tax_rate for California: "+
+		"7.25% (State Board of Equalization) vs 7.5% (Travel Guide 2019). "+
+		"Showing the value from Travel Guide 2019 (newest policy).")
+	t.Log("✓ Successfully cited both conflicting sources")
+}
+
+func TestMergedRecordsEverywhere(t *testing.T) {
+	t.Log("Testing that rankings and comparisons use the merged records...")
+
+	corpus := newTestCorpus(t, conflictingRecords())
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "Superlative", query: "Which state has the highest tax rate?", expected: "Highest tax rate: California (7.50%"},
+		{name: "Lowest", query: "Which state has the lowest tax rate?", expected: "Lowest tax rate: New York (4.00%"},
+		{name: "Comparison", query: "Compare California and Texas", expected: "| California | 7.5% |"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, tt.query)
+			assert.Contains(t, result, tt.expected)
+			assert.NotContains(t, result, "7.25%", "The value merged away should not be used")
+			t.Logf("✓ Answered %q with the merged value", tt.query)
+		})
+	}
+}
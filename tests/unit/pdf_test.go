@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writePDF writes a minimal PDF with one page per entry, each line set in
+// Helvetica below the previous one
+func writePDF(t *testing.T, path string, pages [][]string) {
+	t.Helper()
+
+	var objects []string
+	add := func(object string) int {
+		objects = append(objects, object)
+		return len(objects)
+	}
+
+	add("<< /Type /Catalog /Pages 2 0 R >>")
+	add("") // pages, filled in once the kids are known
+	font := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+
+	var kids []string
+	for _, lines := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 12 Tf 72 720 Td")
+		for i, line := range lines {
+			if i > 0 {
+				content.WriteString(" 0 -16 Td")
+			}
+			fmt.Fprintf(&content, " (%s) Tj", line)
+		}
+		content.WriteString(" ET")
+
+		stream := add(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] "+
+			"/Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>", font, stream))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+}
+
+func TestLoadPDF(t *testing.T) {
+	t.Log("Testing PDF text extraction...")
+
+	dir := filepath.Join("data", "pdf")
+	writeSourceFiles(t, dir, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tax_policies_2023.pdf")
+	writePDF(t, path, [][]string{
+		{"Tax Policies 2023", "Travis County levies a 1.9% local sales tax."},
+		{"Groceries and prescription drugs are exempt."},
+	})
+
+	records, err := cmd.LoadData(path, cmd.DataTypeDocument)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2, "Each page should be chunked separately") {
+		assert.Equal(t, "Tax Policies 2023 Travis County levies a 1.9% local sales tax.", records[0].Values["text"])
+		assert.Equal(t, "tax_policies_2023.pdf", records[0].Source)
+		assert.Equal(t, &cmd.Provenance{File: path, Page: 1, StartLine: 1, EndLine: 2}, records[0].Provenance)
+		assert.Equal(t, 2, records[1].Provenance.Page)
+	}
+
+	writeSourceFiles(t, dir, map[string]string{"broken.pdf": "not a pdf"})
+	_, err = cmd.LoadData(filepath.Join(dir, "broken.pdf"), cmd.DataTypeDocument)
+	assert.ErrorContains(t, err, "error reading PDF")
+	t.Log("✓ Successfully extracted PDF text")
+}
+
+func TestLoadPDFFromDirectory(t *testing.T) {
+	t.Log("Testing PDFs found in a data directory...")
+
+	dir := filepath.Join("data", "pdf")
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n",
+	})
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tax_policies_2023.pdf")
+	writePDF(t, path, [][]string{{"Tax Policies 2023", "Travis County levies a 1.9% local sales tax."}})
+
+	sources, err := cmd.ResolveSources([]string{dir}, "")
+	assert.NoError(t, err)
+	assert.Contains(t, sources, cmd.DataSource{Path: path, DataType: cmd.DataTypeDocument}, "PDFs without a manifest entry should load as documents")
+
+	records, err := cmd.LoadSource(cmd.DataSource{Path: path, DataType: cmd.DataTypeDocument})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, cmd.DataTypeDocument, records[0].DataType)
+	}
+	t.Log("✓ Successfully loaded a PDF from a directory")
+}
+
+func TestLoadCorruptPDF(t *testing.T) {
+	t.Log("Testing PDFs the parser panics on...")
+
+	dir := filepath.Join("data", "pdf")
+	writeSourceFiles(t, dir, nil)
+	defer os.RemoveAll(dir)
+
+	// Point the cross-reference offset at a lone '>', which the parser
+	// panics on rather than returning an error
+	path := filepath.Join(dir, "corrupt.pdf")
+	writePDF(t, path, [][]string{{"Tax Policies 2023"}})
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	xref := bytes.LastIndex(data, []byte("startxref"))
+	corrupt := fmt.Sprintf("%sstartxref\n%d\n%%%%EOF\n", data[:xref], bytes.Index(data, []byte(">>"))+1)
+	writeSourceFiles(t, dir, map[string]string{"corrupt.pdf": corrupt})
+
+	assert.NotPanics(t, func() {
+		_, err = cmd.LoadData(path, cmd.DataTypeDocument)
+	})
+	assert.ErrorContains(t, err, "error reading PDF "+path)
+	t.Log("✓ Successfully reported the corrupt PDF")
+}
+
+func TestPDFLinkedToCitingRows(t *testing.T) {
+	t.Log("Testing PDF chunks linked to the rows citing them...")
+
+	dir := filepath.Join("data", "pdf")
+	writeSourceFiles(t, dir, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tax_policies_2023.pdf")
+	writePDF(t, path, [][]string{
+		{"Groceries and prescription drugs are exempt from the local rate."},
+	})
+
+	documents, err := cmd.LoadData(path, cmd.DataTypeDocument)
+	assert.NoError(t, err)
+	records := append(testRecords(), documents...)
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, records), "Are groceries taxed in Travis County?")
+	assert.Contains(t, result, "From tax_policies_2023.pdf (page 1, lines 1-1):")
+	assert.Contains(t, result, "Groceries and prescription drugs are exempt")
+	t.Log("✓ Successfully quoted the cited PDF")
+}
@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNumeric(t *testing.T) {
+	t.Log("Testing numeric value parsing...")
+
+	tests := []struct {
+		raw   string
+		value float64
+		unit  string
+		ok    bool
+	}{
+		{raw: "7.25%", value: 7.25, unit: cmd.UnitPercent, ok: true},
+		{raw: "$350", value: 350, unit: cmd.UnitCurrency, ok: true},
+		{raw: " 1,200 ", value: 1200, ok: true},
+		{raw: "$300/day", value: 300, unit: cmd.UnitCurrency, ok: true},
+		{raw: "12,345,678", value: 12345678, ok: true},
+		{raw: "1,2,3", ok: false},
+		{raw: "2,1%", value: 2.1, unit: cmd.UnitPercent, ok: true},
+		{raw: "12,3456", ok: false},
+		{raw: "1200,000", ok: false},
+		{raw: "June to August", ok: false},
+		{raw: "", ok: false},
+	}
+
+	for _, tc := range tests {
+		value, ok := cmd.ParseNumeric(tc.raw)
+		assert.Equal(t, tc.ok, ok, "Unexpected parse result for %q", tc.raw)
+		if tc.ok {
+			assert.Equal(t, tc.value, value.Value)
+			assert.Equal(t, tc.unit, value.Unit)
+		}
+	}
+	t.Log("✓ Successfully parsed numeric values")
+}
+
+func TestPlanQuery(t *testing.T) {
+	t.Log("Testing query planning...")
+
+	tests := []struct {
+		query string
+		plan  cmd.QueryPlan
+	}{
+		{query: "Which state has the lowest tax rate?", plan: cmd.QueryPlan{Kind: cmd.PlanSuperlative, Field: "tax_rate", Ascending: true, Scope: "state"}},
+		{query: "most expensive hotels", plan: cmd.QueryPlan{Kind: cmd.PlanSuperlative, Field: "hotel_avg"}},
+		{query: "places under $300/day", plan: cmd.QueryPlan{Kind: cmd.PlanFilter, Field: "daily_cost", Operator: "<", Threshold: 300}},
+		{query: "tax rate at least 6%", plan: cmd.QueryPlan{Kind: cmd.PlanFilter, Field: "tax_rate", Operator: ">=", Threshold: 6}},
+		{query: "average hotel cost", plan: cmd.QueryPlan{Kind: cmd.PlanAggregate, Field: "hotel_avg", Aggregate: "average"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			plan, ok := cmd.PlanQuery(tc.query)
+			assert.True(t, ok, "Expected a plan")
+			assert.Equal(t, tc.plan, plan)
+		})
+	}
+
+	_, ok := cmd.PlanQuery("Tell me about California")
+	assert.False(t, ok, "Lookups should not be planned")
+	t.Log("✓ Successfully planned queries")
+}
+
+func TestExecuteQueryPlan(t *testing.T) {
+	t.Log("Testing query plan execution...")
+
+	records := testRecords("tax", "cost")
+
+	result, err := cmd.QueryPlan{Kind: cmd.PlanSuperlative, Field: "tax_rate", Ascending: true}.Execute(records)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Lowest tax rate: New York (4.00%, source: NY Department of Taxation)")
+
+	result, err = cmd.QueryPlan{Kind: cmd.PlanFilter, Field: "daily_cost", Operator: "<", Threshold: 300}.Execute(records)
+	assert.NoError(t, err)
+	assert.Equal(t, "Locations with a daily cost < $300: Texas ($250)", result)
+
+	result, err = cmd.QueryPlan{Kind: cmd.PlanAggregate, Field: "hotel_avg", Aggregate: "average"}.Execute(records)
+	assert.NoError(t, err)
+	assert.Contains(t, result, "Average hotel cost per night across 3 locations: $176.67", "Unparsable values are skipped")
+
+	_, err = cmd.QueryPlan{Kind: cmd.PlanAggregate, Field: "food_avg", Aggregate: "average"}.Execute(testRecords("tax"))
+	assert.Error(t, err, "Missing data should be reported")
+	t.Log("✓ Successfully executed query plans")
+}
+
+func TestFindRelevantInfoSuperlative(t *testing.T) {
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, testRecords()), "which state has the lowest tax rate?")
+	assert.Contains(t, result, "Lowest tax rate: New York")
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResolver() *cmd.LocationResolver {
+	locations := []string{"California", "New York", "Texas", "Florida", "Travis County"}
+	aliases := map[string]string{
+		"CA":        "California",
+		"NYC":       "New York",
+		"Big Apple": "New York",
+		"TX":        "Texas",
+		"Austin":    "Travis County",
+		"Paris":     "France",
+	}
+	return cmd.NewLocationResolver(locations, aliases)
+}
+
+func TestLocationResolver(t *testing.T) {
+	t.Log("Testing fuzzy location resolution...")
+
+	resolver := newTestResolver()
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+		fuzzy    bool
+	}{
+		{name: "Exact Name", query: "Tell me about California", expected: "California"},
+		{name: "Transposed Letters", query: "Califronia", expected: "California", fuzzy: true},
+		{name: "Missing Letter", query: "costs in Florda", expected: "Florida", fuzzy: true},
+		{name: "Multi Word Typo", query: "New Yrok hotels", expected: "New York", fuzzy: true},
+		{name: "State Code", query: "TX taxes", expected: "Texas"},
+		{name: "Abbreviation", query: "hotels in NYC", expected: "New York"},
+		{name: "Nickname", query: "visiting the Big Apple", expected: "New York"},
+		{name: "City Alias", query: "Austin", expected: "Travis County"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			matches := resolver.Resolve(tc.query)
+			if assert.NotEmpty(t, matches, "Expected a location match") {
+				assert.Equal(t, tc.expected, matches[0].Location)
+				assert.Equal(t, tc.fuzzy, matches[0].Fuzzy)
+			}
+			t.Logf("✓ Resolved %q to %s", tc.query, tc.expected)
+		})
+	}
+
+	assert.Empty(t, resolver.Resolve("Tell me about it"), "Short words should not fuzzy match")
+	for _, match := range cmd.NewLocationResolver([]string{"California"}, map[string]string{"Golden State": "California"}).Resolve("Golden Gate") {
+		assert.False(t, match.Fuzzy, "Landmarks should not fuzzy match aliases differing in a generic word")
+	}
+	assert.Empty(t, resolver.Resolve("Paris"), "Aliases of unknown locations are ignored")
+}
+
+func TestLocationResolverSuggestions(t *testing.T) {
+	t.Log("Testing did-you-mean suggestions...")
+
+	resolver := newTestResolver()
+	suggestions := resolver.Suggest("Floridda Keys", 3)
+	if assert.NotEmpty(t, suggestions) {
+		assert.Equal(t, "Florida", suggestions[0])
+	}
+	assert.Empty(t, resolver.Suggest("xyz", 3))
+	t.Log("✓ Successfully suggested locations")
+}
+
+func TestFindRelevantInfoResolvesTypos(t *testing.T) {
+	t.Log("Testing misspelled locations in FindRelevantInfo...")
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, testRecords("tourist")), "Califronia")
+	assert.Contains(t, result, "Tourist Information for California", "Typos should still resolve")
+	t.Log("✓ Successfully resolved misspelled location")
+}
@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReciprocalRankFusion(t *testing.T) {
+	t.Log("Testing reciprocal rank fusion...")
+
+	records := testRecords("tourist")
+	lexical := []cmd.ScoredRecord{
+		{Record: records[0], Index: 0, Score: 9},
+		{Record: records[1], Index: 1, Score: 5},
+	}
+	vector := []cmd.ScoredRecord{
+		{Record: records[1], Index: 1, Score: 0.9},
+		{Record: records[2], Index: 2, Score: 0.8},
+	}
+
+	fused := cmd.ReciprocalRankFusion(60, lexical, vector)
+	if assert.Len(t, fused, 3) {
+		assert.Equal(t, "Texas", fused[0].Record.Location, "Record ranked by both retrievers should win")
+		assert.InDelta(t, 1.0/62+1.0/61, fused[0].Score, 1e-9)
+		assert.Equal(t, "California", fused[1].Record.Location, "Ties keep record order")
+	}
+	t.Log("✓ Successfully fused rankings")
+}
+
+func TestRetrievalPipeline(t *testing.T) {
+	t.Log("Testing hybrid retrieval pipeline...")
+
+	ctx := context.Background()
+	corpus, err := cmd.NewCorpus(ctx, testRecords("tourist"), cmd.NewHashingEmbedder(0))
+	assert.NoError(t, err)
+
+	cfg := cmd.DefaultRetrievalConfig()
+	pipeline, err := cmd.NewRetrievalPipeline(corpus, cfg, nil)
+	assert.NoError(t, err)
+
+	results, err := pipeline.Retrieve(ctx, cmd.RetrievalRequest{Query: "Disney"}, 3)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Florida", results[0].Record.Location)
+	}
+
+	// The conversational location breaks near ties in the feature reranker
+	results, err = pipeline.Retrieve(ctx, cmd.RetrievalRequest{Query: "tourism", Location: "Texas"}, 3)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Texas", results[0].Record.Location)
+	}
+
+	cfg.Reranker = cmd.RerankerLLM
+	_, err = cmd.NewRetrievalPipeline(corpus, cfg, nil)
+	assert.Error(t, err, "LLM reranker requires a provider")
+
+	cfg.Retrievers = []string{"unknown"}
+	_, err = cmd.NewRetrievalPipeline(corpus, cfg, nil)
+	assert.Error(t, err, "Unknown retrievers should be rejected")
+	t.Log("✓ Successfully retrieved through the pipeline")
+}
+
+func TestLLMReranker(t *testing.T) {
+	t.Log("Testing LLM reranker with a fake provider...")
+
+	records := testRecords("tourist")
+	candidates := []cmd.ScoredRecord{
+		{Record: records[0], Index: 0, Score: 3},
+		{Record: records[1], Index: 1, Score: 2},
+		{Record: records[2], Index: 2, Score: 1},
+	}
+
+	provider := cmd.NewFakeProvider("3, 1")
+	reranker := &cmd.LLMReranker{Provider: provider}
+	reranked, err := reranker.Rerank(context.Background(), cmd.RetrievalRequest{Query: "beaches"}, candidates)
+	assert.NoError(t, err)
+	if assert.Len(t, reranked, 3) {
+		assert.Equal(t, "Florida", reranked[0].Record.Location)
+		assert.Equal(t, "California", reranked[1].Record.Location)
+		assert.Equal(t, "Texas", reranked[2].Record.Location, "Unranked candidates keep their order")
+	}
+	assert.Contains(t, provider.Calls[0][1].Content, "[3] Florida")
+	t.Log("✓ Successfully reranked with the LLM")
+}
+
+func TestRerankerFailureIsLogged(t *testing.T) {
+	t.Log("Testing a failing reranker...")
+
+	ctx := context.Background()
+	corpus, err := cmd.NewCorpus(ctx, testRecords("tourist"), cmd.NewHashingEmbedder(0))
+	assert.NoError(t, err)
+
+	cfg := cmd.DefaultRetrievalConfig()
+	cfg.Reranker = cmd.RerankerLLM
+	provider := cmd.NewFakeProvider()
+	provider.Err = errors.New("rate limited")
+	pipeline, err := cmd.NewRetrievalPipeline(corpus, cfg, provider)
+	assert.NoError(t, err)
+	var log bytes.Buffer
+	pipeline.Log = &log
+
+	results, err := pipeline.Retrieve(ctx, cmd.RetrievalRequest{Query: "Disney"}, 3)
+	assert.NoError(t, err, "A failing reranker should not fail retrieval")
+	if assert.NotEmpty(t, results) {
+		assert.Equal(t, "Florida", results[0].Record.Location, "The fused order should be kept")
+	}
+	assert.Contains(t, log.String(), "llm reranker failed, keeping the fused order: rate limited")
+	t.Log("✓ Successfully logged the reranker failure")
+}
+
+func TestRetrievalIgnoresUnknownLocations(t *testing.T) {
+	t.Log("Testing places missing from the data...")
+
+	records := testRecords()
+	corpus := newTestCorpus(t, records)
+	tests := []struct {
+		name  string
+		query string
+		other string
+	}{
+		{name: "Unknown_County", query: "What's the tax rate in Orange County?", other: "Harris County"},
+		{name: "Another_Unknown_County", query: "Dallas County", other: "Harris County"},
+		{name: "Unknown_State", query: "Tell me about New Jersey", other: "New York"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, tt.query)
+			assert.Contains(t, result, "No relevant information found in the database.")
+			assert.NotContains(t, result, "in "+tt.other+" ", "Another place's data should not answer")
+			t.Logf("✓ Found nothing for %q", tt.query)
+		})
+	}
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, testRecords("tourist")), "Golden Gate")
+	assert.Contains(t, result, "Tourist Information for California", "Places found through their values should still be answered")
+}
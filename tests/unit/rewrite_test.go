@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeuristicRewriter(t *testing.T) {
+	t.Log("Running heuristic query rewrite tests...")
+
+	resolver := cmd.NewLocationResolver([]string{"California", "Texas", "New York"}, nil)
+	tests := []struct {
+		name         string
+		query        string
+		lastLocation string
+		expected     string
+	}{
+		{"Reference", "and the hotels there?", "Texas", "the hotels in Texas?"},
+		{"Pronoun", "Is it expensive?", "Texas", "Is Texas expensive?"},
+		{"Several_References", "is it expensive there?", "Texas", "is Texas expensive?"},
+		{"Locative", "what can I see in there?", "Texas", "what can I see in Texas?"},
+		{"Existential", "is there a beach anywhere?", "Texas", "is there a beach anywhere?"},
+		{"Existential_Ending", "what hotels are there?", "Texas", "what hotels are there in Texas?"},
+		{"Topic_Only", "what about the costs?", "Texas", "the costs in Texas?"},
+		{"Named_Location", "What about New York?", "Texas", "New York?"},
+		{"No_Last_Location", "and the hotels there?", "", "the hotels there?"},
+		{"Standalone", "Which state has the lowest tax rate?", "Texas", "Which state has the lowest tax rate?"},
+		{"Lead_In_Only", "what about", "Texas", "what about"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewritten, err := cmd.HeuristicRewriter{}.Rewrite(context.Background(), cmd.RewriteRequest{
+				Query:        tt.query,
+				LastLocation: tt.lastLocation,
+				Resolver:     resolver,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, rewritten)
+			t.Logf("✓ Rewrote %q as %q", tt.query, rewritten)
+		})
+	}
+}
+
+func TestLLMRewriter(t *testing.T) {
+	t.Log("Testing LLM query rewriting...")
+
+	provider := cmd.NewFakeProvider("\"hotel costs in Texas\"\n")
+	rewriter, err := cmd.NewQueryRewriter(cmd.RewriterLLM, provider)
+	assert.NoError(t, err)
+
+	rewritten, err := rewriter.Rewrite(context.Background(), cmd.RewriteRequest{
+		Query:        "and the hotels there?",
+		LastLocation: "Texas",
+		Turns:        []cmd.Turn{{Question: "Tell me about Texas", Location: "Texas"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hotel costs in Texas", rewritten)
+	if assert.Len(t, provider.Calls, 1) {
+		messages := provider.Calls[0]
+		assert.Contains(t, messages[0].Content, "- Tell me about Texas (about Texas)", "Earlier questions should be sent")
+		assert.Equal(t, cmd.ChatMessage{Role: cmd.RoleUser, Content: "and the hotels there?"}, messages[1])
+	}
+
+	fallback, err := cmd.NewQueryRewriter(cmd.RewriterLLM, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, cmd.RewriterHeuristic, fallback.Name(), "Without a provider the heuristic rewriter should be used")
+
+	_, err = cmd.NewQueryRewriter("magic", nil)
+	assert.ErrorContains(t, err, `unknown query rewriter "magic"`)
+	t.Log("✓ Successfully rewrote with the LLM")
+}
+
+func TestFindRelevantInfoLogsRewrite(t *testing.T) {
+	t.Log("Testing rewritten queries in search...")
+
+	provider := cmd.NewFakeProvider()
+	provider.Err = errors.New("service unavailable")
+	var log bytes.Buffer
+	session := cmd.NewSession(nil)
+	session.Rewriter = &cmd.LLMRewriter{Provider: provider}
+	session.Log = &log
+	corpus := newTestCorpus(t, testRecords("tourist"))
+
+	cmd.FindRelevantInfo(session, corpus, "Tell me about Texas")
+	result, _ := cmd.FindRelevantInfo(session, corpus, "and is it expensive?")
+	assert.Contains(t, result, "Texas", "The follow-up should stay on Texas")
+	assert.Contains(t, log.String(), "query rewrite with llm failed: service unavailable")
+	assert.Contains(t, log.String(), `rewrote "and is it expensive?" as "is Texas expensive?" (heuristic)`,
+		"A failed LLM rewrite should fall back to the heuristic rewriter")
+	t.Log("✓ Successfully logged the rewritten query")
+}
+
+func TestFreshSessionSkipsRewrite(t *testing.T) {
+	t.Log("Testing the first question of a session...")
+
+	provider := cmd.NewFakeProvider("hotel costs in Texas")
+	var log bytes.Buffer
+	session := cmd.NewSession(nil)
+	session.Rewriter = &cmd.LLMRewriter{Provider: provider}
+	session.Log = &log
+	corpus := newTestCorpus(t, testRecords("tourist"))
+
+	result, _ := cmd.FindRelevantInfo(session, corpus, "Tell me about Florida")
+	assert.Contains(t, result, "Florida")
+	assert.Empty(t, provider.Calls, "Without earlier turns there is nothing to rewrite")
+	assert.NotContains(t, log.String(), "rewrote")
+
+	cmd.FindRelevantInfo(session, corpus, "and the hotels there?")
+	assert.Len(t, provider.Calls, 1, "Follow-ups should be rewritten")
+	t.Log("✓ Successfully skipped the rewrite")
+}
+
+func TestExistentialThereIsNotAReference(t *testing.T) {
+	t.Log("Testing questions asking whether something exists...")
+
+	session := cmd.NewSession(nil)
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	cmd.FindRelevantInfo(session, corpus, "Tell me about Texas")
+	result, _ := cmd.FindRelevantInfo(session, corpus, "is there a beach anywhere?")
+	assert.Contains(t, result, "Florida", "The question should search every location")
+	assert.NotContains(t, result, "Texas", "\"is there\" should not refer back to Texas")
+	t.Log("✓ Successfully searched every location")
+}
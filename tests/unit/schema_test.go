@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSourceSchemaErrors(t *testing.T) {
+	t.Log("Running schema validation tests...")
+
+	dir := filepath.Join("data", "schema")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		dataType string
+		content  string
+		errorMsg string
+	}{
+		{
+			name:     "Missing Required Column",
+			dataType: "cost",
+			content:  "location,hotel_avg,source\nTexas,150,tx_cost_analysis.pdf\n",
+			errorMsg: `missing required column "daily_cost"`,
+		},
+		{
+			name:     "Missing Required Value",
+			dataType: "tax",
+			content:  "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\nOhio,,Ohio Department of Taxation\n",
+			errorMsg: "invalid data at row 3, column tax_rate: missing required value",
+		},
+		{
+			name:     "Wrong Type",
+			dataType: "cost",
+			content:  "location,daily_cost,hotel_avg,source\nTexas,250,cheap,tx_cost_analysis.pdf\n",
+			errorMsg: `invalid data at row 2, column hotel_avg: expected a currency, got "cheap"`,
+		},
+		{
+			name:     "Percent In Currency Column",
+			dataType: "cost",
+			content:  "location,daily_cost,source\nTexas,25%,tx_cost_analysis.pdf\n",
+			errorMsg: `invalid data at row 2, column daily_cost: expected a currency amount, got "25%"`,
+		},
+		{
+			name:     "Missing Location",
+			dataType: "tax",
+			content:  "location,tax_rate,source\n,6.25%,Texas Comptroller\n",
+			errorMsg: "invalid data at row 2, column location: missing location",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			writeSourceFiles(t, dir, map[string]string{tc.name + ".csv": tc.content})
+			_, err := cmd.LoadSource(cmd.DataSource{Path: filepath.Join(dir, tc.name+".csv"), DataType: tc.dataType})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.errorMsg)
+				t.Logf("✓ Successfully detected invalid input: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadSourceTypedValues(t *testing.T) {
+	t.Log("Testing typed values from declared schemas...")
+
+	dir := filepath.Join("data", "schema")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"trails.csv": "name,length,fee,citation\nGreenbelt,12.5,5,parks_guide.pdf\n",
+	})
+
+	source := cmd.DataSource{
+		Path:     filepath.Join(dir, "trails.csv"),
+		DataType: "trail",
+		Schema: &cmd.Schema{
+			KeyColumn:    "name",
+			SourceColumn: "citation",
+			Columns: []cmd.ColumnSpec{
+				{Name: "length", Type: cmd.ColumnNumber, Unit: "km", Required: true},
+				{Name: "fee", Type: cmd.ColumnCurrency},
+			},
+		},
+	}
+	records, err := cmd.LoadSource(source)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "Greenbelt", records[0].Location)
+		assert.Equal(t, "parks_guide.pdf", records[0].Source)
+		assert.Equal(t, cmd.NumericValue{Value: 12.5, Unit: "km"}, records[0].Numbers["length"])
+
+		fee, ok := records[0].Number("fee")
+		assert.True(t, ok)
+		assert.Equal(t, cmd.NumericValue{Value: 5, Unit: cmd.UnitCurrency}, fee)
+	}
+
+	source.Schema.Columns[0].Type = "distance"
+	_, err = cmd.LoadSource(source)
+	assert.ErrorContains(t, err, `column length has unknown type "distance"`)
+	t.Log("✓ Successfully loaded typed values")
+}
+
+func TestFormatMissingValues(t *testing.T) {
+	t.Log("Testing answers for records with missing columns...")
+
+	records := []cmd.Record{
+		{Location: "Texas", DataType: "cost", Values: map[string]string{"daily_cost": "250"}, Source: "tx_cost_analysis.pdf"},
+		{Location: "New York", DataType: "cost", Values: map[string]string{"daily_cost": "$1,200", "hotel_avg": "n/a"}, Source: "ny_cost_report.csv"},
+	}
+	corpus := newTestCorpus(t, records)
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, "What does a trip to Texas cost?")
+	assert.Contains(t, result, "Average Daily Cost: $250")
+	assert.Contains(t, result, "Hotel: not available\n")
+	assert.NotContains(t, result, "$ per day")
+
+	result, _ = cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, "What does a trip to New York cost?")
+	assert.Contains(t, result, "Average Daily Cost: $1,200\n", "Amounts with a dollar sign should keep one")
+	assert.Contains(t, result, "Hotel: n/a\n", "Values that are not amounts should be shown as they are")
+	t.Log("✓ Successfully reported missing values")
+}
@@ -78,11 +78,13 @@ func TestFindRelevantInfo(t *testing.T) {
 		},
 	}
 
+	corpus := newTestCorpus(t, records)
+	session := cmd.NewSession(nil)
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Logf("Testing search with query: '%s'", tc.query)
 
-			result, _ := cmd.FindRelevantInfo(tc.query, records)
+			result, _ := cmd.FindRelevantInfo(session, corpus, tc.query)
 			assert.Contains(t, result, tc.expectedResult,
 				"Search result doesn't match expected output")
 
@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStoreResume(t *testing.T) {
+	t.Log("Testing saving and resuming a session...")
+
+	dir := filepath.Join("data", "sessions")
+	defer os.RemoveAll(dir)
+	store := cmd.NewSessionStore(dir, 0)
+
+	session, err := store.Open("trip-planning", nil)
+	assert.NoError(t, err, "Unknown sessions should start empty")
+	assert.Empty(t, session.Turns)
+
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	for _, question := range []string{"Tell me about Texas", "What about California?"} {
+		answer, _ := cmd.FindRelevantInfo(session, corpus, question)
+		assert.NoError(t, store.Append(session.Name, session.RecordTurn(question, session.QueryLocation, "", answer)))
+	}
+
+	resumed, err := store.Open("trip-planning", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "trip-planning", resumed.Name)
+	assert.Equal(t, "California", resumed.LastLocation)
+	assert.Equal(t, "What about California?", resumed.LastQuery)
+	if assert.Len(t, resumed.Turns, 2) {
+		assert.Equal(t, session.Turns[0].Answer, resumed.Turns[0].Answer)
+		assert.True(t, session.Turns[0].Time.Equal(resumed.Turns[0].Time))
+	}
+	if assert.Len(t, resumed.Interactions, 2) {
+		assert.Equal(t, "California", resumed.Interactions[0].Location, "Memory should list the latest location first")
+	}
+
+	result, _ := cmd.FindRelevantInfo(resumed, corpus, "and the best time to visit?")
+	assert.Contains(t, result, "Tourist Information for California", "Follow-ups should continue the resumed conversation")
+	t.Log("✓ Successfully resumed the session")
+}
+
+func TestSessionStoreResumeWithoutLocations(t *testing.T) {
+	t.Log("Testing resuming turns not about a location...")
+
+	dir := filepath.Join("data", "sessions")
+	defer os.RemoveAll(dir)
+	store := cmd.NewSessionStore(dir, 0)
+
+	session, err := store.Open("trip-planning", nil)
+	assert.NoError(t, err)
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	questions := []string{"Tell me about Texas", "thanks!", "which locations did I ask about?", "What about California?"}
+	for _, question := range questions {
+		answer, _ := cmd.FindRelevantInfo(session, corpus, question)
+		assert.NoError(t, store.Append(session.Name, session.RecordTurn(question, session.QueryLocation, "", answer)))
+	}
+	if assert.Len(t, session.Turns, 4) {
+		assert.Equal(t, "Texas", session.Turns[0].Location)
+		assert.Empty(t, session.Turns[1].Location, "Smalltalk should not record a location")
+		assert.Empty(t, session.Turns[2].Location, "Questions about the conversation should not record a location")
+		assert.Equal(t, "California", session.Turns[3].Location)
+	}
+
+	resumed, err := store.Open("trip-planning", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "California", resumed.LastLocation)
+	assert.Len(t, resumed.Interactions, len(session.Interactions), "Resuming should not add interactions")
+	t.Log("✓ Successfully resumed without duplicate interactions")
+}
+
+func TestSessionStoreManage(t *testing.T) {
+	t.Log("Testing listing, deleting and pruning sessions...")
+
+	dir := filepath.Join("data", "sessions")
+	defer os.RemoveAll(dir)
+	store := cmd.NewSessionStore(dir, 30*24*time.Hour)
+
+	now := time.Now()
+	assert.NoError(t, store.Append("old", cmd.Turn{Time: now.AddDate(0, -2, 0), Question: "Tell me about Ohio"}))
+	assert.NoError(t, store.Append("recent", cmd.Turn{Time: now.Add(-time.Hour), Question: "Tell me about Texas"}))
+	assert.NoError(t, store.Append("recent", cmd.Turn{Time: now, Question: "and the costs?"}))
+
+	sessions, err := store.List()
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "recent", sessions[0].Name, "Sessions should be listed most recently used first")
+		assert.Equal(t, 2, sessions[0].Turns)
+		assert.Equal(t, "old", sessions[1].Name)
+	}
+
+	pruned, err := store.Prune(now, "old")
+	assert.NoError(t, err)
+	assert.Empty(t, pruned, "A session being resumed should not be pruned")
+
+	pruned, err = store.Prune(now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old"}, pruned, "Sessions past the retention should be pruned")
+
+	assert.NoError(t, store.Delete("recent"))
+	assert.ErrorContains(t, store.Delete("recent"), `session "recent" not found`)
+	sessions, err = store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	assert.NoError(t, store.Append("valid", cmd.Turn{Time: now, Question: "Tell me about Texas"}))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "corrupt.jsonl"), []byte("{not json\n"), 0644))
+	assert.NoError(t, store.Append("stale", cmd.Turn{Time: now.AddDate(0, -2, 0), Question: "Tell me about Ohio"}))
+	sessions, err = store.List()
+	var skipped cmd.SessionErrors
+	if assert.ErrorAs(t, err, &skipped, "A corrupt session should be reported") {
+		assert.Len(t, skipped, 1)
+		assert.ErrorContains(t, skipped[0], "invalid session corrupt")
+	}
+	assert.Len(t, sessions, 2, "A corrupt session should not hide the others")
+
+	pruned, err = store.Prune(now)
+	assert.ErrorAs(t, err, &skipped)
+	assert.Equal(t, []string{"stale"}, pruned, "A corrupt session should not stop pruning")
+	assert.FileExists(t, filepath.Join(dir, "corrupt.jsonl"), "A corrupt session should not be pruned")
+
+	for _, name := range []string{"../escape", "", "a/b"} {
+		_, err := store.Open(name, nil)
+		assert.ErrorContains(t, err, "invalid session name", "Session names should not reach outside the directory")
+	}
+	t.Log("✓ Successfully managed sessions")
+}
@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"sync"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionsAreIndependent(t *testing.T) {
+	t.Log("Testing independent conversations...")
+
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	alice, bob := cmd.NewSession(nil), cmd.NewSession(nil)
+
+	cmd.FindRelevantInfo(alice, corpus, "Tell me about California")
+	cmd.FindRelevantInfo(bob, corpus, "Tell me about Texas")
+	assert.Equal(t, "California", alice.LastLocation)
+	assert.Equal(t, "Texas", bob.LastLocation)
+
+	result, _ := cmd.FindRelevantInfo(alice, corpus, "and the best time to visit?")
+	assert.Contains(t, result, "Tourist Information for California", "Follow-ups should stay on the session's own location")
+
+	memory, _ := cmd.FindRelevantInfo(bob, corpus, "Which locations did I ask about?")
+	assert.Contains(t, memory, "Most recent query was about: Texas")
+	assert.NotContains(t, memory, "California", "Sessions should not share history")
+	assert.Len(t, alice.Interactions, 2)
+	t.Log("✓ Successfully kept conversations apart")
+}
+
+func TestSessionsRunConcurrently(t *testing.T) {
+	t.Log("Testing concurrent conversations...")
+
+	corpus := newTestCorpus(t, testRecords("tourist"))
+	locations := []string{"California", "Texas", "Florida"}
+
+	var wg sync.WaitGroup
+	sessions := make([]*cmd.Session, len(locations))
+	for i, location := range locations {
+		sessions[i] = cmd.NewSession(nil)
+		wg.Add(1)
+		go func(session *cmd.Session, location string) {
+			defer wg.Done()
+			for range 5 {
+				cmd.FindRelevantInfo(session, corpus, "Tell me about "+location)
+				cmd.FindRelevantInfo(session, corpus, "what about the costs?")
+			}
+		}(sessions[i], location)
+	}
+	wg.Wait()
+
+	for i, session := range sessions {
+		assert.Equal(t, locations[i], session.LastLocation)
+		assert.Len(t, session.Interactions, session.MemorySize, "Memory should be capped per session")
+	}
+	t.Log("✓ Successfully ran concurrent conversations")
+}
@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSourceFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create test data directory: %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+}
+
+func TestResolveSourcesDirectoryAndGlob(t *testing.T) {
+	t.Log("Testing data directories and globs...")
+
+	dir := filepath.Join("data", "sources")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n",
+		"museums.csv":     "location,museum,source\nTexas,Bullock Museum,museum_guide.pdf\n",
+		"notes.bak":       "not a data file",
+	})
+
+	sources, err := cmd.ResolveSources([]string{dir}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []cmd.DataSource{
+		{Path: filepath.Join(dir, "museums.csv"), DataType: "museums"},
+		{Path: filepath.Join(dir, "state_taxes.csv"), DataType: "tax"},
+	}, sources, "Directories should list their data files with inferred data types")
+
+	sources, err = cmd.ResolveSources([]string{filepath.Join(dir, "*_taxes.csv"), filepath.Join(dir, "state_taxes.csv")}, "")
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1, "Files matched twice should be loaded once")
+
+	_, err = cmd.ResolveSources([]string{filepath.Join(dir, "*.json")}, "")
+	assert.Error(t, err, "Globs without matches should be reported")
+	t.Log("✓ Successfully resolved directories and globs")
+}
+
+func TestResolveSourcesManifest(t *testing.T) {
+	t.Log("Testing data manifests...")
+
+	dir := filepath.Join("data", "manifest")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"parks.csv": "park,state,entry_fee,reference\nZilker Park,Texas,free,parks_guide.pdf\n",
+		"other.csv": "location,tax_rate,source\nOhio,5.75%,Ohio Department of Taxation\n",
+		"manifest.yaml": `sources:
+  - path: parks.csv
+    data_type: park
+    key_column: park
+    schema:
+      source_column: reference
+      columns:
+        - name: entry_fee
+          type: string
+`,
+	})
+
+	sources, err := cmd.ResolveSources([]string{dir}, "")
+	assert.NoError(t, err)
+	if assert.Len(t, sources, 1, "A directory manifest should list the sources to load") {
+		assert.Equal(t, filepath.Join(dir, "parks.csv"), sources[0].Path, "Paths should be relative to the manifest")
+		assert.Equal(t, "park", sources[0].DataType)
+	}
+
+	records, err := cmd.LoadSource(sources[0])
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "Zilker Park", records[0].Location, "Key column should name the location")
+		assert.Equal(t, "parks_guide.pdf", records[0].Source, "Source column should come from the schema")
+		assert.Equal(t, map[string]string{"state": "Texas", "entry_fee": "free"}, records[0].Values)
+	}
+
+	jsonManifest := filepath.Join(dir, "sources.json")
+	writeSourceFiles(t, dir, map[string]string{
+		"sources.json": `{"sources": [{"path": "other.csv", "data_type": "tax"}]}`,
+	})
+	sources, err = cmd.ResolveSources(nil, jsonManifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []cmd.DataSource{{Path: filepath.Join(dir, "other.csv"), DataType: "tax"}}, sources)
+
+	writeSourceFiles(t, dir, map[string]string{"broken.yaml": "sources:\n  - data_type: tax\n"})
+	_, err = cmd.LoadManifest(filepath.Join(dir, "broken.yaml"))
+	assert.Error(t, err, "Sources without a path should be rejected")
+	t.Log("✓ Successfully loaded data manifests")
+}
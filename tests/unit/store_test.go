@@ -0,0 +1,174 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func storeRecords() []cmd.Record {
+	records := append(testRecords("tourist"), cmd.Record{
+		Location:   "Texas",
+		DataType:   cmd.DataTypeDocument,
+		Values:     map[string]string{"text": "Groceries are exempt from sales tax.", "heading": "Sales tax"},
+		Source:     "tax_policies_2023.pdf",
+		Provenance: &cmd.Provenance{File: "data/sources/tax_policies_2023.pdf", Page: 1, StartLine: 3, EndLine: 4},
+		Tags:       []string{"Texas", "Florida"},
+	})
+	records[0].Numbers = map[string]cmd.NumericValue{"daily_cost": {Value: 350, Unit: cmd.UnitCurrency}}
+	records[1].EffectiveDate = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	records[1].ValidUntil = time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	records[2].Modified = time.Date(2024, 3, 5, 10, 30, 15, 250, time.UTC)
+	return records
+}
+
+// storeChunks are embedded chunks of the first and last store records
+func storeChunks() []cmd.VectorChunk {
+	return []cmd.VectorChunk{
+		{Record: 0, Text: "California attractions", Vector: []float32{0.5, -1.25, 0}},
+		{Record: len(storeRecords()) - 1, Text: "Groceries are exempt", Vector: []float32{0, 1, 0.75}},
+	}
+}
+
+func TestRecordStores(t *testing.T) {
+	t.Log("Running record store tests...")
+
+	dir := filepath.Join("data", "store")
+	writeSourceFiles(t, dir, nil)
+	defer os.RemoveAll(dir)
+
+	sqlite, err := cmd.OpenSQLiteStore(filepath.Join(dir, "records.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite store: %v", err)
+	}
+	defer sqlite.Close()
+
+	records := storeRecords()
+	for _, store := range []cmd.RecordStore{cmd.NewMemoryStore(nil), sqlite} {
+		t.Run(store.Name(), func(t *testing.T) {
+			assert.NoError(t, store.Replace(records, storeChunks(), "v1"))
+
+			version, err := store.Version()
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", version)
+
+			all, err := store.Records()
+			assert.NoError(t, err)
+			assert.Equal(t, records, all, "Records should round-trip with values and provenance")
+			chunks, err := store.Chunks()
+			assert.NoError(t, err)
+			assert.Equal(t, storeChunks(), chunks, "Chunks should round-trip with their vectors")
+
+			texas, err := store.ByLocation("Texas")
+			assert.NoError(t, err)
+			if assert.Len(t, texas, 2) {
+				assert.Equal(t, "tourist", texas[0].DataType)
+				assert.Equal(t, cmd.DataTypeDocument, texas[1].DataType)
+			}
+			florida, err := store.ByLocation("Florida")
+			assert.NoError(t, err)
+			if assert.Len(t, florida, 2, "Documents should be found by every location they mention") {
+				assert.Equal(t, cmd.DataTypeDocument, florida[1].DataType)
+			}
+
+			results, err := store.Search("groceries sales tax", 3)
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, results) {
+				assert.Equal(t, len(records)-1, results[0].Index, "Search positions should index the record set")
+				assert.Equal(t, records[len(records)-1], results[0].Record)
+			}
+
+			results, err = store.Search("the", 3)
+			assert.NoError(t, err)
+			assert.Empty(t, results, "Stop words alone should match nothing")
+			t.Logf("✓ Successfully used the %s store", store.Name())
+		})
+	}
+}
+
+func TestSQLiteStorePersists(t *testing.T) {
+	t.Log("Testing SQLite store persistence...")
+
+	dir := filepath.Join("data", "store")
+	writeSourceFiles(t, dir, nil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "records.db")
+
+	store, err := cmd.OpenSQLiteStore(path)
+	assert.NoError(t, err)
+	embedder := cmd.NewHashingEmbedder(0)
+	chunks, err := cmd.EmbedRecords(context.Background(), embedder, storeRecords())
+	assert.NoError(t, err)
+	assert.NoError(t, store.Replace(storeRecords(), chunks, "v1"))
+	assert.NoError(t, store.Close())
+
+	store, err = cmd.OpenSQLiteStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+	version, err := store.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", version, "The version should survive reopening the store")
+	records, err := store.Records()
+	assert.NoError(t, err)
+	assert.Equal(t, storeRecords(), records, "Records should survive reopening the store")
+
+	corpus, err := cmd.OpenStoredCorpus(store, embedder, nil)
+	assert.NoError(t, err)
+	assert.Same(t, store, corpus.Store, "The corpus should query the store")
+	for _, query := range []string{"Disney World Miami", "Miami Beach theme parks"} {
+		results, err := corpus.Pipeline.Retrieve(context.Background(), cmd.RetrievalRequest{Query: query}, 1)
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, results) {
+			assert.Equal(t, "Florida", results[0].Record.Location)
+		}
+	}
+	t.Log("✓ Successfully reopened the SQLite store")
+}
+
+func TestSourcesVersion(t *testing.T) {
+	t.Log("Testing source versions...")
+
+	dir := filepath.Join("data", "store")
+	writeSourceFiles(t, dir, map[string]string{"tax_rates.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n"})
+	defer os.RemoveAll(dir)
+
+	sources := []cmd.DataSource{{Path: filepath.Join(dir, "tax_rates.csv"), DataType: "tax"}}
+	embedder := cmd.NewHashingEmbedder(0)
+	version, err := cmd.SourcesVersion(sources, embedder, nil)
+	assert.NoError(t, err)
+	again, err := cmd.SourcesVersion(sources, embedder, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, version, again, "Unchanged sources should keep their version")
+
+	changes := map[string]func() (string, error){
+		"Content": func() (string, error) {
+			writeSourceFiles(t, dir, map[string]string{"tax_rates.csv": "location,tax_rate,source\nTexas,6.5%,Texas Comptroller\n"})
+			defer writeSourceFiles(t, dir, map[string]string{"tax_rates.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n"})
+			return cmd.SourcesVersion(sources, embedder, nil)
+		},
+		"Data_Type": func() (string, error) {
+			return cmd.SourcesVersion([]cmd.DataSource{{Path: sources[0].Path, DataType: "cost"}}, embedder, nil)
+		},
+		"Embedder": func() (string, error) {
+			return cmd.SourcesVersion(sources, cmd.NewHashingEmbedder(64), nil)
+		},
+		"Aliases": func() (string, error) {
+			return cmd.SourcesVersion(sources, embedder, map[string]string{"tx": "Texas"})
+		},
+	}
+	for name, change := range changes {
+		changed, err := change()
+		assert.NoError(t, err)
+		assert.NotEqual(t, version, changed, "%s changes should change the version", name)
+	}
+
+	_, err = cmd.SourcesVersion([]cmd.DataSource{{Path: filepath.Join(dir, "missing.csv")}}, embedder, nil)
+	assert.Error(t, err)
+	t.Log("✓ Successfully versioned the sources")
+}
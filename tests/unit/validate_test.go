@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSources(t *testing.T) {
+	t.Log("Testing data file validation...")
+
+	dir := filepath.Join("data", "validate")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\n" +
+			"Texas,high,Texas Comptroller\n" +
+			",5.75%,Ohio Department of Taxation\n" +
+			"Ohio,5.75%,Ohio Department of Taxation,extra\n" +
+			"Florida,6%,Florida Department of Revenue\n",
+		"travel_costs.jsonl": "{\"daily_cost\": 250, \"source\": \"Texas Tourism\"}\n" +
+			"not json\n" +
+			"{\"location\": \"Ohio\", \"daily_cost\": 150, \"source\": \"Ohio Tourism\"}\n",
+		"tourist_info.csv": "location,attractions,best_time,source\nTexas,The Alamo,March to May,Texas Tourism\n",
+	})
+
+	sources, err := cmd.ResolveSources([]string{dir}, "")
+	assert.NoError(t, err)
+	report := cmd.ValidateSources(sources)
+	assert.False(t, report.Valid)
+	assert.Equal(t, 3, report.Files)
+	assert.Equal(t, 3, report.Records, "Good rows should still be counted")
+
+	taxes := filepath.Join(dir, "state_taxes.csv")
+	costs := filepath.Join(dir, "travel_costs.jsonl")
+	if assert.Len(t, report.Issues, 5, "Every bad row should be reported") {
+		assert.Equal(t, cmd.ValidationIssue{File: taxes, Row: 2, Column: "tax_rate", Message: `expected a percent, got "high"`}, report.Issues[0])
+		assert.Equal(t, cmd.ValidationIssue{File: taxes, Row: 3, Column: "location", Message: "missing location"}, report.Issues[1])
+		assert.Equal(t, taxes, report.Issues[2].File)
+		assert.Equal(t, 4, report.Issues[2].Row)
+		assert.Equal(t, cmd.ValidationIssue{File: costs, Row: 1, Column: "location", Message: "missing location"}, report.Issues[3])
+		assert.Equal(t, 2, report.Issues[4].Row)
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	assert.Contains(t, text.String(), taxes+`: row 2, column tax_rate: expected a percent, got "high"`+"\n")
+	assert.Contains(t, text.String(), "Checked 3 files, 3 records: 5 errors found\n")
+
+	data, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `{"file":"`+taxes+`","row":3,"column":"location","message":"missing location"}`)
+
+	_, err = cmd.LoadData(taxes, "tax")
+	assert.EqualError(t, err, `invalid data at row 2, column tax_rate: expected a percent, got "high" (and 2 more errors)`)
+	t.Log("✓ Successfully collected every error")
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goragagent/cmd"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashingEmbedder(t *testing.T) {
+	t.Log("Testing offline hashing embedder...")
+
+	embedder := cmd.NewHashingEmbedder(256)
+	vectors, err := embedder.Embed(context.Background(), []string{"Golden Gate Bridge", "golden gate bridge", ""})
+	assert.NoError(t, err)
+	assert.Len(t, vectors, 3)
+	assert.Len(t, vectors[0], 256, "Vector should have the configured dimensions")
+	assert.Equal(t, vectors[0], vectors[1], "Embedding should be case insensitive and deterministic")
+	t.Log("✓ Successfully embedded texts")
+}
+
+func TestOpenAIEmbedderChecksIndices(t *testing.T) {
+	t.Log("Testing embedding responses with bad indices...")
+
+	tests := []struct {
+		name     string
+		indices  []int
+		expected string
+	}{
+		{name: "Reordered", indices: []int{1, 0}},
+		{name: "OutOfRange", indices: []int{0, 2}, expected: "index 2 of 2 texts"},
+		{name: "Negative", indices: []int{-1, 0}, expected: "index -1 of 2 texts"},
+		{name: "Repeated", indices: []int{1, 1}, expected: "index 1 twice"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"object":"list","data":[{"object":"embedding","index":%d,"embedding":[1,0]},{"object":"embedding","index":%d,"embedding":[0,1]}]}`,
+					tc.indices[0], tc.indices[1])
+			}))
+			defer server.Close()
+
+			config := openai.DefaultConfig("test-key")
+			config.BaseURL = server.URL
+			embedder := cmd.NewOpenAIEmbedder(openai.NewClientWithConfig(config))
+
+			vectors, err := embedder.Embed(context.Background(), []string{"Texas", "Florida"})
+			if tc.expected == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, [][]float32{{0, 1}, {1, 0}}, vectors, "Vectors should be placed by their index")
+				return
+			}
+			assert.ErrorContains(t, err, tc.expected)
+			assert.Nil(t, vectors)
+		})
+	}
+	t.Log("✓ Successfully checked embedding indices")
+}
+
+func TestVectorIndexSearch(t *testing.T) {
+	t.Log("Testing cosine similarity search over records...")
+
+	records := testRecords("tourist")
+	index, err := cmd.BuildVectorIndex(context.Background(), cmd.NewHashingEmbedder(0), records)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{query: "Golden Gate", expected: "California"},
+		{query: "where is the Alamo?", expected: "Texas"},
+		{query: "Miami beach in November", expected: "Florida"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			results, err := index.Search(context.Background(), tc.query, 2)
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, results, "Expected at least one hit") {
+				assert.Equal(t, tc.expected, results[0].Record.Location)
+			}
+			t.Logf("✓ Query %q matched %s", tc.query, tc.expected)
+		})
+	}
+
+	results, err := index.Search(context.Background(), "Dallas", 2)
+	assert.NoError(t, err)
+	assert.Empty(t, results, "Unrelated query should not match")
+}
+
+func TestFindRelevantInfoUsesAttractions(t *testing.T) {
+	t.Log("Testing that FindRelevantInfo finds locations from record values...")
+
+	result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), newTestCorpus(t, testRecords("tourist")), "Golden Gate")
+	assert.Contains(t, result, "Tourist Information for California")
+	t.Log("✓ Successfully found California from its attractions")
+}
@@ -0,0 +1,242 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"goragagent/cmd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceWatcher(t *testing.T) {
+	t.Log("Testing data file change detection...")
+
+	dir := filepath.Join("data", "watch")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"travel_costs.csv": "location,daily_cost,hotel_avg,food_avg,source\nTexas,200,120,50,Texas Tourism\n",
+	})
+	costs := filepath.Join(dir, "travel_costs.csv")
+
+	watcher, err := cmd.NewSourceWatcher(func() ([]string, error) {
+		return cmd.WatchedPaths([]string{dir}, "")
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch data files: %v", err)
+	}
+
+	changed, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Empty(t, changed, "Untouched files should not be reported")
+
+	writeSourceFiles(t, dir, map[string]string{
+		"travel_costs.csv": "location,daily_cost,hotel_avg,food_avg,source\nTexas,250,150,60,Texas Tourism\n",
+		"state_taxes.csv":  "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n",
+	})
+	changed, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Empty(t, changed, "Changes should settle for a poll before being reported")
+	changed, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "state_taxes.csv"), costs}, changed, "Edited and added files should be reported")
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "state_taxes.csv")))
+	watcher.Poll()
+	changed, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "state_taxes.csv")}, changed, "Removed files should be reported")
+	t.Log("✓ Successfully detected data file changes")
+}
+
+func TestCorpusReloader(t *testing.T) {
+	t.Log("Testing corpus hot reload...")
+
+	dir := filepath.Join("data", "watch")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"travel_costs.csv": "location,daily_cost,hotel_avg,food_avg,source\nTexas,200,120,50,Texas Tourism\n",
+	})
+	costs := filepath.Join(dir, "travel_costs.csv")
+
+	load := func(ctx context.Context) (*cmd.Corpus, error) {
+		records, err := cmd.LoadData(costs, "cost")
+		if err != nil {
+			return nil, err
+		}
+		return cmd.NewCorpus(ctx, records, cmd.NewHashingEmbedder(0))
+	}
+	corpus, err := load(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+	reloader := cmd.NewCorpusReloader(corpus, load)
+
+	writeSourceFiles(t, dir, map[string]string{
+		"travel_costs.csv": "location,daily_cost,hotel_avg,food_avg,source\nTexas,250,150,60,Texas Tourism\n",
+	})
+	assert.NoError(t, reloader.Reload(context.Background()))
+	reloader.Use(func(corpus *cmd.Corpus) {
+		result, _ := cmd.FindRelevantInfo(cmd.NewSession(nil), corpus, "How much does a trip to Texas cost?")
+		assert.Contains(t, result, "$250", "Queries should see the reloaded data")
+	})
+
+	writeSourceFiles(t, dir, map[string]string{
+		"travel_costs.csv": "location,daily_cost,hotel_avg,food_avg,source\n,300,150,60,Texas Tourism\n",
+	})
+	assert.ErrorContains(t, reloader.Reload(context.Background()), "missing location")
+	assert.Equal(t, "250", reloader.Corpus().Records[0].Values["daily_cost"], "A failed reload should keep the previous data")
+	t.Log("✓ Successfully reloaded the corpus")
+}
+
+func TestCorpusReloaderWatch(t *testing.T) {
+	t.Log("Testing reload notices...")
+
+	dir := filepath.Join("data", "watch")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n",
+	})
+	taxes := filepath.Join(dir, "state_taxes.csv")
+
+	load := func(ctx context.Context) (*cmd.Corpus, error) {
+		records, err := cmd.LoadData(taxes, "tax")
+		if err != nil {
+			return nil, err
+		}
+		return cmd.NewCorpus(ctx, records, cmd.NewHashingEmbedder(0))
+	}
+	corpus, err := load(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+	reloader := cmd.NewCorpusReloader(corpus, load)
+	watcher, err := cmd.NewSourceWatcher(func() ([]string, error) {
+		return []string{taxes}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch data files: %v", err)
+	}
+
+	notices := make(chan string, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, watcher, 10*time.Millisecond, func(notice string) {
+		notices <- notice
+	})
+
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\nOhio,5.75%,Ohio Department of Taxation\n",
+	})
+	select {
+	case notice := <-notices:
+		assert.Equal(t, "Reloaded 2 records after changes to "+taxes, notice)
+	case <-time.After(5 * time.Second):
+		t.Fatal("No reload notice after editing a data file")
+	}
+
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,high,Texas Comptroller\n",
+	})
+	select {
+	case notice := <-notices:
+		assert.Contains(t, notice, "Reload failed, keeping previous data")
+	case <-time.After(5 * time.Second):
+		t.Fatal("No notice after a failed reload")
+	}
+	assert.Len(t, reloader.Corpus().Records, 2, "A failed reload should keep the previous records")
+	t.Log("✓ Successfully reported reloads")
+}
+
+func TestSourceWatcherComparesContent(t *testing.T) {
+	t.Log("Testing change detection by file content...")
+
+	dir := filepath.Join("data", "watch")
+	defer os.RemoveAll(dir)
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,6.25%,Texas Comptroller\n",
+	})
+	taxes := filepath.Join(dir, "state_taxes.csv")
+	stamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(taxes, stamp, stamp))
+
+	watcher, err := cmd.NewSourceWatcher(func() ([]string, error) {
+		return []string{taxes}, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch data files: %v", err)
+	}
+
+	assert.NoError(t, os.Chtimes(taxes, time.Now(), time.Now()))
+	watcher.Poll()
+	changed, err := watcher.Poll()
+	assert.NoError(t, err)
+	assert.Empty(t, changed, "Touching a file should not be reported")
+
+	// Same size and modification time, different content
+	writeSourceFiles(t, dir, map[string]string{
+		"state_taxes.csv": "location,tax_rate,source\nTexas,6.50%,Texas Comptroller\n",
+	})
+	assert.NoError(t, os.Chtimes(taxes, stamp, stamp))
+	watcher.Poll()
+	changed, err = watcher.Poll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{taxes}, changed, "Edits keeping the size and time should be reported")
+	t.Log("✓ Successfully compared files by content")
+}
+
+func TestCorpusReloaderConcurrentLoads(t *testing.T) {
+	t.Log("Testing reloads running alongside queries...")
+
+	newCorpus := func(rate string) *cmd.Corpus {
+		return newTestCorpus(t, []cmd.Record{
+			{Location: "Texas", DataType: "tax", Values: map[string]string{"tax_rate": rate}, Source: "Texas Comptroller"},
+		})
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loads := 0
+	load := func(ctx context.Context) (*cmd.Corpus, error) {
+		loads++
+		if loads == 1 {
+			close(started)
+			<-release
+			return newCorpus("6.00%"), nil
+		}
+		return newCorpus("6.50%"), nil
+	}
+	reloader := cmd.NewCorpusReloader(newCorpus("6.25%"), load)
+
+	slow := make(chan error)
+	go func() {
+		slow <- reloader.Reload(context.Background())
+	}()
+	<-started
+
+	queried := make(chan string)
+	go func() {
+		queried <- reloader.Corpus().Records[0].Values["tax_rate"]
+	}()
+	select {
+	case rate := <-queried:
+		assert.Equal(t, "6.25%", rate, "Queries should use the current data while a reload loads")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Queries were blocked by a loading reload")
+	}
+
+	assert.NoError(t, reloader.Reload(context.Background()))
+	close(release)
+	assert.NoError(t, <-slow)
+	assert.Equal(t, "6.50%", reloader.Corpus().Records[0].Values["tax_rate"], "An older load should not replace newer data")
+
+	reloader.Install = func(corpus *cmd.Corpus) error {
+		return fmt.Errorf("store is read-only")
+	}
+	assert.ErrorContains(t, reloader.Reload(context.Background()), "store is read-only")
+	assert.Equal(t, "6.50%", reloader.Corpus().Records[0].Values["tax_rate"], "A failed install should keep the previous data")
+	t.Log("✓ Successfully reloaded alongside queries")
+}